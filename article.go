@@ -205,10 +205,10 @@ func (c *Client) FavoritesArticle(param FavoritesArticleParam) error {
 }
 
 type GetUserArticleListParam struct {
-	Mid  int    `json:"mid"`                                      // 用户uid
-	Pn   int    `json:"pn,omitempty" request:"query,omitempty"`   // 默认：1
-	Ps   int    `json:"ps,omitempty" request:"query,omitempty"`   // 默认：30。范围：[1,30]
-	Sort string `json:"sort,omitempty" request:"query,omitempty"` // publish_time：最新发布。view：最多阅读。fav：最多收藏。默认：publish_time
+	Mid  int    `json:"mid"`                                                 // 用户uid
+	Pn   int    `json:"pn,omitempty" request:"query,omitempty"`              // 默认：1
+	Ps   int    `json:"ps,omitempty" request:"query,omitempty,min=1,max=30"` // 默认：30。范围：[1,30]
+	Sort string `json:"sort,omitempty" request:"query,omitempty"`            // publish_time：最新发布。view：最多阅读。fav：最多收藏。默认：publish_time
 }
 
 type UserArticleList struct {
@@ -0,0 +1,531 @@
+// Package bilibilitest 提供一个进程内的模拟B站服务器，用于在不访问网络的情况下测试使用 bilibili.Client 的代码。
+//
+//	server := bilibilitest.NewServer()
+//	defer server.Close()
+//
+//	client := server.NewClient()
+//	info, err := client.GetVideoInfo(bilibili.VideoParam{Bvid: "BV17x411w7KC"})
+//
+// 模拟服务器实现了以下功能：
+//   - 与B站一致的 {"code":0,"message":"0","data":{}} 返回格式
+//   - 登录后下发 SESSDATA、bili_jct、DedeUserID 等 cookies，并对带有 csrf 参数的请求进行校验
+//   - 获取 WBI 签名密钥的 nav 接口，并对路径中包含 /wbi/ 的接口校验 w_rid
+//   - 扫码登录的状态流转：86101（未扫码） → 86090（已扫码未确认） → 0（登录成功）
+//   - 使用 refresh_token 刷新cookies的完整流程，可以通过 ExpireSession 令会话需要刷新
+//   - 视频、用户、收藏夹、评论、IP定位等常用接口的固定返回数据，见 fixtures 目录
+//
+// 可以通过 Handle 和 HandleFixture 添加或覆盖任意接口，通过 HandleRaw 处理短链接跳转、视频流等非 JSON 的请求。
+package bilibilitest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rinkurt/bilibili"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// 默认的 WBI 签名密钥，与B站文档中的示例一致
+const (
+	DefaultImgKey = "7cd084941338484aae1ad9425b84077c"
+	DefaultSubKey = "4932caff0ff746eab6f01bf08b70ac45"
+)
+
+// 扫码登录的各个状态码
+const (
+	QRCodeNotScanned = 86101 // 未扫码
+	QRCodeScanned    = 86090 // 二维码已扫码未确认
+	QRCodeExpired    = 86038 // 二维码已失效
+	QRCodeConfirmed  = 0     // 扫码登录成功
+)
+
+// HandlerFunc 处理一个接口请求，返回的 data 会被包装成 {"code":0,"message":"0","data":data} 的格式。
+// w 只应用于设置响应头（例如 Set-Cookie），不要向其写入内容。
+//
+// 如果返回的 err 是 bilibili.Error，则会以其 Code 和 Message 作为返回的 code 和 message；
+// 如果是 bilibili.StatusError，则会返回其 StatusCode 作为HTTP状态码，例如模拟 412 风控；
+// 其它的 err 会导致服务器返回 500 状态码。
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) (data any, err error)
+
+// Session 是一个登录会话
+type Session struct {
+	Mid          int    // 用户mid
+	SESSDATA     string // SESSDATA cookie
+	BiliJct      string // bili_jct cookie，即 csrf
+	RefreshToken string // 登录时返回的 refresh_token
+
+	needRefresh bool     // cookie/info 接口是否返回需要刷新
+	refreshCsrf string   // correspond 页面下发的 refresh_csrf
+	previous    *Session // 刷新前的会话，确认刷新后失效
+}
+
+// Cookies 返回该会话对应的 cookies
+func (s *Session) Cookies() []*http.Cookie {
+	expires := time.Now().Add(180 * 24 * time.Hour)
+	newCookie := func(name, value string) *http.Cookie {
+		return &http.Cookie{Name: name, Value: value, Domain: "bilibili.com", Path: "/", Expires: expires}
+	}
+	return []*http.Cookie{
+		newCookie("SESSDATA", s.SESSDATA),
+		newCookie("bili_jct", s.BiliJct),
+		newCookie("DedeUserID", strconv.Itoa(s.Mid)),
+	}
+}
+
+// Server 是模拟的B站服务器，它同时代替了 api.bilibili.com、api.vc.bilibili.com、passport.bilibili.com、api.live.bilibili.com、www.bilibili.com
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	handlers    map[string]HandlerFunc
+	rawHandlers map[string]http.HandlerFunc
+	imgKey      string
+	subKey      string
+	sessions    map[string]*Session // SESSDATA -> Session
+	qrcodes     map[string]int      // qrcode_key -> 状态码
+	loginMid    int                 // 扫码登录成功后的用户mid
+	counts      map[string]int      // path -> 请求次数
+}
+
+// NewServer 创建并启动一个模拟服务器，使用完毕后需要调用 Close 关闭
+func NewServer() *Server {
+	s := &Server{
+		handlers:    make(map[string]HandlerFunc, 16),
+		rawHandlers: make(map[string]http.HandlerFunc, 4),
+		imgKey:      DefaultImgKey,
+		subKey:      DefaultSubKey,
+		sessions:    make(map[string]*Session, 4),
+		qrcodes:     make(map[string]int, 4),
+		loginMid:    1,
+		counts:      make(map[string]int, 16),
+	}
+	s.registerDefaults()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewClient 返回一个所有请求都指向该模拟服务器的 bilibili.Client
+func (s *Server) NewClient() *bilibili.Client {
+	return s.Configure(bilibili.New())
+}
+
+// Configure 将已有的 client 的请求都指向该模拟服务器
+func (s *Server) Configure(client *bilibili.Client) *bilibili.Client {
+	return client.SetAllHosts(s.URL)
+}
+
+// Handle 添加或覆盖 path 对应的接口，path 不包含域名，例如 "/x/web-interface/view"
+func (s *Server) Handle(path string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[path] = handler
+}
+
+// HandleRaw 令 path 对应的请求直接由 handler 处理，响应不会被包装成 JSON 格式，可以用于模拟短链接的跳转和视频流的下载
+func (s *Server) HandleRaw(path string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rawHandlers[path] = handler
+}
+
+// HandleFixture 令 path 对应的接口固定返回 data。
+// data 可以是 []byte 或 json.RawMessage 格式的 JSON，也可以是任意可以被 json.Marshal 的值。
+func (s *Server) HandleFixture(path string, data any) {
+	if b, ok := data.([]byte); ok {
+		data = json.RawMessage(b)
+	}
+	s.Handle(path, func(http.ResponseWriter, *http.Request) (any, error) {
+		return data, nil
+	})
+}
+
+// HandleError 令 path 对应的接口固定返回错误码 code
+func (s *Server) HandleError(path string, code int, message string) {
+	s.Handle(path, func(http.ResponseWriter, *http.Request) (any, error) {
+		return nil, bilibili.Error{Code: code, Message: message}
+	})
+}
+
+// RequestCount 返回 path 被请求的次数
+func (s *Server) RequestCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counts[path]
+}
+
+// SetWbiKeys 修改 nav 接口下发的 WBI 签名密钥
+func (s *Server) SetWbiKeys(imgKey, subKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.imgKey, s.subKey = imgKey, subKey
+}
+
+// WbiKeys 返回当前的 WBI 签名密钥
+func (s *Server) WbiKeys() (imgKey, subKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.imgKey, s.subKey
+}
+
+// SetLoginMid 设置扫码登录成功后登录的用户mid，默认为1
+func (s *Server) SetLoginMid(mid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loginMid = mid
+}
+
+// NewSession 直接为 mid 创建一个登录会话。把 Session.Cookies 设置给 client 就相当于登录成功了。
+func (s *Server) NewSession(mid int) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.newSession(mid)
+}
+
+func (s *Server) newSession(mid int) *Session {
+	session := &Session{
+		Mid:          mid,
+		SESSDATA:     randomHex(16),
+		BiliJct:      randomHex(16),
+		RefreshToken: randomHex(16),
+	}
+	s.sessions[session.SESSDATA] = session
+	return session
+}
+
+// Session 返回请求所属的登录会话，未登录时返回 nil
+func (s *Server) Session(r *http.Request) *Session {
+	cookie, err := r.Cookie("SESSDATA")
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[cookie.Value]
+}
+
+// ExpireSession 令 cookie/info 接口对该会话返回需要刷新cookies
+func (s *Server) ExpireSession(session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.needRefresh = true
+}
+
+// HasSession 返回 SESSDATA 对应的会话是否仍然有效
+func (s *Server) HasSession(sessdata string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[sessdata]
+	return ok
+}
+
+// ScanQRCode 将二维码置为已扫码未确认的状态
+func (s *Server) ScanQRCode(qrcodeKey string) {
+	s.setQRCode(qrcodeKey, QRCodeScanned)
+}
+
+// ConfirmQRCode 将二维码置为已确认的状态，下一次轮询时即登录成功
+func (s *Server) ConfirmQRCode(qrcodeKey string) {
+	s.setQRCode(qrcodeKey, QRCodeConfirmed)
+}
+
+// ExpireQRCode 将二维码置为已失效的状态
+func (s *Server) ExpireQRCode(qrcodeKey string) {
+	s.setQRCode(qrcodeKey, QRCodeExpired)
+}
+
+func (s *Server) setQRCode(qrcodeKey string, state int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.qrcodes[qrcodeKey]; ok {
+		s.qrcodes[qrcodeKey] = state
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.counts[r.URL.Path]++
+	handler, ok := s.handlers[r.URL.Path]
+	rawHandler := s.rawHandlers[r.URL.Path]
+	s.mu.Unlock()
+
+	if rawHandler != nil {
+		rawHandler(w, r)
+		return
+	}
+	if !ok && strings.HasPrefix(r.URL.Path, correspondPrefix) {
+		s.serveCorrespond(w, r)
+		return
+	}
+	if !ok {
+		writeError(w, bilibili.ErrNotFound)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/wbi/") && !s.verifyWbi(r.URL.Query()) {
+		writeError(w, bilibili.ErrNoPermission)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if csrf := r.Form.Get("csrf"); len(csrf) > 0 {
+		session := s.Session(r)
+		if session == nil {
+			writeError(w, bilibili.ErrNotLoggedIn)
+			return
+		}
+		if csrf != session.BiliJct {
+			writeError(w, bilibili.ErrCsrfFailed)
+			return
+		}
+	}
+
+	data, err := handler(w, r)
+	if err != nil {
+		if e, ok := err.(bilibili.Error); ok {
+			writeResp(w, e.Code, e.Message, data)
+			return
+		}
+		if e, ok := err.(bilibili.StatusError); ok {
+			w.WriteHeader(e.StatusCode)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResp(w, 0, "0", data)
+}
+
+// verifyWbi 校验 WBI 签名
+func (s *Server) verifyWbi(query url.Values) bool {
+	wRid := query.Get("w_rid")
+	if len(wRid) == 0 || len(query.Get("wts")) == 0 {
+		return false
+	}
+	query.Del("w_rid")
+
+	imgKey, subKey := s.WbiKeys()
+	mixinKey := bilibili.NewDefaultWbi().GenerateMixinKey(imgKey + subKey)
+	hash := md5.Sum([]byte(query.Encode() + mixinKey))
+	return hex.EncodeToString(hash[:]) == wRid
+}
+
+func (s *Server) registerDefaults() {
+	for path, file := range map[string]string{
+		"/x/web-interface/view":   "video_view.json",
+		"/x/web-interface/zone":   "zone.json",
+		"/x/player/pagelist":      "video_pagelist.json",
+		"/x/web-interface/card":   "user_card.json",
+		"/x/space/wbi/acc/info":   "user_space_info.json",
+		"/x/space/wbi/arc/search": "user_videos.json",
+		"/x/v3/fav/folder/info":   "fav_folder_info.json",
+		"/x/v3/fav/resource/list": "fav_resource_list.json",
+		"/x/v2/reply":             "comment_reply.json",
+	} {
+		data, err := fixtures.ReadFile("fixtures/" + file)
+		if err != nil {
+			panic(err)
+		}
+		s.HandleFixture(path, data)
+	}
+
+	s.handlers["/x/web-interface/nav"] = s.handleNav
+	s.handlers["/x/passport-login/web/qrcode/generate"] = s.handleQRCodeGenerate
+	s.handlers["/x/passport-login/web/qrcode/poll"] = s.handleQRCodePoll
+	s.handlers["/x/passport-login/web/cookie/info"] = s.handleCookieInfo
+	s.handlers["/x/passport-login/web/cookie/refresh"] = s.handleCookieRefresh
+	s.handlers["/x/passport-login/web/confirm/refresh"] = s.handleConfirmRefresh
+	s.handlers["/x/report/click/now"] = func(http.ResponseWriter, *http.Request) (any, error) {
+		return map[string]any{"now": time.Now().Unix()}, nil
+	}
+}
+
+func (s *Server) handleNav(_ http.ResponseWriter, r *http.Request) (any, error) {
+	imgKey, subKey := s.WbiKeys()
+	data := map[string]any{
+		"isLogin": false,
+		"wbi_img": map[string]any{
+			"img_url": "https://i0.hdslb.com/bfs/wbi/" + imgKey + ".png",
+			"sub_url": "https://i0.hdslb.com/bfs/wbi/" + subKey + ".png",
+		},
+	}
+	session := s.Session(r)
+	if session == nil {
+		return data, bilibili.ErrNotLoggedIn
+	}
+	data["isLogin"] = true
+	data["mid"] = session.Mid
+	return data, nil
+}
+
+func (s *Server) handleQRCodeGenerate(http.ResponseWriter, *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := randomHex(16)
+	s.qrcodes[key] = QRCodeNotScanned
+	return map[string]any{
+		"url":        "https://passport.bilibili.com/h5-app/passport/login/scan?navhide=1&qrcode_key=" + key,
+		"qrcode_key": key,
+	}, nil
+}
+
+// handleQRCodePoll 每次轮询都会使二维码前进到下一个状态，也可以通过 ScanQRCode 等方法直接修改状态
+func (s *Server) handleQRCodePoll(w http.ResponseWriter, r *http.Request) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.URL.Query().Get("qrcode_key")
+	state, ok := s.qrcodes[key]
+	if !ok {
+		state = QRCodeExpired
+	}
+	data := struct {
+		Url          string `json:"url"`
+		RefreshToken string `json:"refresh_token"`
+		Timestamp    int64  `json:"timestamp"`
+		Code         int    `json:"code"`
+		Message      string `json:"message"`
+	}{Code: state}
+
+	switch state {
+	case QRCodeNotScanned:
+		data.Message = "未扫码"
+		s.qrcodes[key] = QRCodeScanned
+	case QRCodeScanned:
+		data.Message = "二维码已扫码未确认"
+		s.qrcodes[key] = QRCodeConfirmed
+	case QRCodeExpired:
+		data.Message = "二维码已失效"
+	case QRCodeConfirmed:
+		delete(s.qrcodes, key)
+		session := s.newSession(s.loginMid)
+		data.Message = "0"
+		data.RefreshToken = session.RefreshToken
+		data.Timestamp = time.Now().UnixMilli()
+		data.Url = "https://passport.biligame.com/x/passport-login/web/crossDomain?DedeUserID=" + strconv.Itoa(session.Mid)
+		for _, cookie := range session.Cookies() {
+			http.SetCookie(w, cookie)
+		}
+	}
+	return data, nil
+}
+
+const correspondPrefix = "/correspond/1/"
+
+// serveCorrespond 返回包含 refresh_csrf 的页面。模拟服务器没有对应的私钥，所以不校验 correspondPath
+func (s *Server) serveCorrespond(w http.ResponseWriter, r *http.Request) {
+	session := s.Session(r)
+	if session == nil || len(r.URL.Path) == len(correspondPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	session.refreshCsrf = randomHex(16)
+	refreshCsrf := session.refreshCsrf
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(`<!DOCTYPE html><html><body><div id="1-name">` + refreshCsrf + `</div></body></html>`))
+}
+
+func (s *Server) handleCookieInfo(_ http.ResponseWriter, r *http.Request) (any, error) {
+	session := s.Session(r)
+	if session == nil {
+		return nil, bilibili.ErrNotLoggedIn
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]any{"refresh": session.needRefresh, "timestamp": time.Now().UnixMilli()}, nil
+}
+
+// handleCookieRefresh 校验 refresh_csrf 和 refresh_token 后创建一个新的会话，旧的会话在确认刷新前仍然有效
+func (s *Server) handleCookieRefresh(w http.ResponseWriter, r *http.Request) (any, error) {
+	session := s.Session(r)
+	if session == nil {
+		return nil, bilibili.ErrNotLoggedIn
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(session.refreshCsrf) == 0 || r.Form.Get("refresh_csrf") != session.refreshCsrf ||
+		r.Form.Get("refresh_token") != session.RefreshToken {
+		return nil, errRequest
+	}
+	session.refreshCsrf = ""
+	newSession := s.newSession(session.Mid)
+	newSession.previous = session
+	for _, cookie := range newSession.Cookies() {
+		http.SetCookie(w, cookie)
+	}
+	return map[string]any{"status": 0, "message": "", "refresh_token": newSession.RefreshToken}, nil
+}
+
+// handleConfirmRefresh 使刷新前的会话失效
+func (s *Server) handleConfirmRefresh(_ http.ResponseWriter, r *http.Request) (any, error) {
+	session := s.Session(r)
+	if session == nil {
+		return nil, bilibili.ErrNotLoggedIn
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := session.previous
+	if previous == nil || r.Form.Get("refresh_token") != previous.RefreshToken {
+		return nil, errRequest
+	}
+	delete(s.sessions, previous.SESSDATA)
+	session.previous = nil
+	return nil, nil
+}
+
+var errRequest = bilibili.Error{Code: -400, Message: "请求错误"}
+
+func writeError(w http.ResponseWriter, e bilibili.Error) {
+	writeResp(w, e.Code, e.Message, nil)
+}
+
+func writeResp(w http.ResponseWriter, code int, message string, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code":    code,
+		"message": message,
+		"ttl":     1,
+		"data":    data,
+	})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
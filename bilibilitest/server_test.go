@@ -0,0 +1,164 @@
+package bilibilitest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestFixtures(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	videoInfo, err := client.GetVideoInfo(bilibili.VideoParam{Bvid: "BV17x411w7KC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if videoInfo.Aid != 170001 || len(videoInfo.Pages) != 2 {
+		t.Fatal("GetVideoInfo result not correct ", videoInfo)
+	}
+
+	userCard, err := client.GetUserCard(bilibili.GetUserCardParam{Mid: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userCard.Card.Mid != "2" {
+		t.Fatal("GetUserCard result not correct ", userCard)
+	}
+
+	favourList, err := client.GetFavourList(bilibili.GetFavourListParam{MediaId: 1052622027, Ps: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favourList.Medias) != 1 || favourList.HasMore {
+		t.Fatal("GetFavourList result not correct ", favourList)
+	}
+
+	comments, err := client.GetCommentsDetail(bilibili.GetCommentsDetailParam{Type: 1, Oid: 170001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments.Replies) != 1 || comments.Replies[0].Content.Message != "测试评论" {
+		t.Fatal("GetCommentsDetail result not correct ", comments)
+	}
+
+	server.HandleError("/x/web-interface/view", -404, "啥都木有")
+	_, err = client.GetVideoInfo(bilibili.VideoParam{Aid: 1})
+	if !bilibili.IsNotFound(err) {
+		t.Fatal("expected error code -404, got ", err)
+	}
+}
+
+func TestWbi(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	userVideos, err := client.GetUserVideos(bilibili.GetUserVideosParam{Mid: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userVideos.List.Vlist) != 1 {
+		t.Fatal("GetUserVideos result not correct ", userVideos)
+	}
+	if server.RequestCount("/x/web-interface/nav") == 0 {
+		t.Fatal("wbi keys should be fetched from the nav endpoint")
+	}
+
+	resp, err := resty.New().R().
+		SetQueryParams(map[string]string{"mid": "2", "wts": "1700000000", "w_rid": "00000000000000000000000000000000"}).
+		Get(server.URL + "/x/space/wbi/arc/search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(resp.Body())
+	if resp.StatusCode() != http.StatusOK || !containsCode(body, -403) {
+		t.Fatal("wrong w_rid should be rejected, got ", body)
+	}
+}
+
+func TestQRCodeLogin(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.SetLoginMid(12345)
+	client := server.NewClient()
+
+	qrCode, err := client.GetQRCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://passport.bilibili.com/x/passport-login/web/qrcode/poll"
+	param := map[string]string{"qrcode_key": qrCode.QrcodeKey}
+	for _, expected := range []int{bilibilitest.QRCodeNotScanned, bilibilitest.QRCodeScanned} {
+		data, err := bilibili.RawExecute(client, http.MethodGet, url, bilibili.ContentTypeUrl, param, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := data.Get("code").MustInt(); code != expected {
+			t.Fatalf("expected qrcode state %d, got %d", expected, code)
+		}
+	}
+
+	result, err := client.LoginWithQRCode(bilibili.LoginWithQRCodeParam{QrcodeKey: qrCode.QrcodeKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != bilibilitest.QRCodeConfirmed || len(result.RefreshToken) == 0 {
+		t.Fatal("LoginWithQRCode result not correct ", result)
+	}
+
+	var sessdata, biliJct string
+	for _, cookie := range client.GetCookies() {
+		switch cookie.Name {
+		case "SESSDATA":
+			sessdata = cookie.Value
+		case "bili_jct":
+			biliJct = cookie.Value
+		}
+	}
+	if len(sessdata) == 0 || len(biliJct) == 0 {
+		t.Fatal("cookies not set after login ", client.GetCookiesString())
+	}
+
+	data, err := bilibili.RawExecute(client, http.MethodGet, url, bilibili.ContentTypeUrl, param, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := data.Get("code").MustInt(); code != bilibilitest.QRCodeExpired {
+		t.Fatal("used qrcode should be expired, got ", code)
+	}
+}
+
+func TestCsrf(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.Handle("/x/v2/history/toview/add", func(http.ResponseWriter, *http.Request) (any, error) {
+		return nil, nil
+	})
+
+	client := server.NewClient()
+	session := server.NewSession(2)
+	client.SetCookies(session.Cookies())
+	if err := client.AddToView(bilibili.VideoParam{Aid: 170001}); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetCookie(&http.Cookie{Name: "bili_jct", Value: "wrong"})
+	err := client.AddToView(bilibili.VideoParam{Aid: 170001})
+	if !bilibili.IsCsrfFailed(err) {
+		t.Fatal("expected error code -111, got ", err)
+	}
+}
+
+func containsCode(body string, code int) bool {
+	var resp struct {
+		Code int `json:"code"`
+	}
+	return json.Unmarshal([]byte(body), &resp) == nil && resp.Code == code
+}
@@ -0,0 +1,242 @@
+package bilibili
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache 储存接口的响应。实现需要是并发安全的，可以基于内存、磁盘或者 Redis 等实现。
+type Cache interface {
+	// Get 返回 key 对应的响应，不存在或者已经过期时返回 false
+	Get(key string) ([]byte, bool)
+	// Set 保存响应，ttl 后过期
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// MemoryCache 是基于内存的 LRU Cache
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List // 最近使用的在前面
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryCache 返回一个最多保存 maxEntries 个响应、总大小不超过 maxBytes 字节的 MemoryCache，超出时淘汰最久没有使用的响应。
+// 小于等于0表示不限制。
+func NewMemoryCache(maxEntries int, maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element, 64),
+		now:        time.Now,
+	}
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if !m.now().Before(entry.expiresAt) {
+		m.removeElement(el)
+		return nil, false
+	}
+	m.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	if m.maxBytes > 0 && int64(len(value)) > m.maxBytes {
+		return
+	}
+	entry := &memoryCacheEntry{key: key, value: value, expiresAt: m.now().Add(ttl)}
+	m.items[key] = m.ll.PushFront(entry)
+	m.bytes += int64(len(value))
+	for m.maxEntries > 0 && m.ll.Len() > m.maxEntries || m.maxBytes > 0 && m.bytes > m.maxBytes {
+		m.removeElement(m.ll.Back())
+	}
+}
+
+// Len 返回保存的响应数，包括已经过期但还没有被清除的
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ll.Len()
+}
+
+func (m *MemoryCache) removeElement(el *list.Element) {
+	entry := m.ll.Remove(el).(*memoryCacheEntry)
+	delete(m.items, entry.key)
+	m.bytes -= int64(len(entry.value))
+}
+
+// ResponseCache 为指定的 GET 接口缓存成功的响应。相同参数的并发请求只会发起一次，命中缓存的调用不经过限流器和重试。
+//
+// 缓存的 key 由接口路径、除 wts、w_rid 以外的 query 参数和当前登录的用户组成，不同账号的响应不会混用。
+//
+//	cache := bilibili.NewResponseCache(bilibili.NewMemoryCache(10000, 64<<20)).
+//		SetEndpointTTL("/x/web-interface/view", 5*time.Minute).
+//		SetEndpointTTL("/x/web-interface/zone", time.Hour)
+//	client.SetResponseCache(cache)
+type ResponseCache struct {
+	store Cache
+
+	mu   sync.RWMutex
+	ttls map[string]time.Duration // 接口路径 -> 缓存时间
+
+	sfg singleflight.Group
+}
+
+// NewResponseCache 返回一个使用 store 保存响应的 ResponseCache，需要通过 SetEndpointTTL 指定缓存哪些接口
+func NewResponseCache(store Cache) *ResponseCache {
+	return &ResponseCache{store: store, ttls: make(map[string]time.Duration, 8)}
+}
+
+// SetEndpointTTL 缓存 path 接口的响应 ttl 时间，path 是不含域名和参数的路径，例如 "/x/web-interface/view"。
+// ttl 小于等于0表示不缓存
+func (rc *ResponseCache) SetEndpointTTL(path string, ttl time.Duration) *ResponseCache {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if ttl <= 0 {
+		delete(rc.ttls, path)
+	} else {
+		rc.ttls[path] = ttl
+	}
+	return rc
+}
+
+func (rc *ResponseCache) ttl(path string) time.Duration {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return rc.ttls[path]
+}
+
+// SetResponseCache 设置响应缓存，传入 nil 表示不缓存
+func (c *Client) SetResponseCache(cache *ResponseCache) *Client {
+	c.responseCache = cache
+	return c
+}
+
+// cacheKey 返回请求的缓存 key 和缓存时间，不需要缓存时返回 false。key 只由 in 中的参数决定，不包括 handlers 添加的签名等参数。
+// 要上传的文件不参与计算，以免在发送请求之前就把它们读完
+func (c *Client) cacheKey(method, rawUrl string, in any) (string, time.Duration, bool) {
+	if c.responseCache == nil || method != http.MethodGet {
+		return "", 0, false
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", 0, false
+	}
+	ttl := c.responseCache.ttl(u.Path)
+	if ttl <= 0 {
+		return "", 0, false
+	}
+	r := c.resty.R()
+	if encodeParams(r, in, true) != nil {
+		return "", 0, false
+	}
+	query := make(url.Values, len(r.QueryParam))
+	for k, v := range r.QueryParam {
+		if k != "wts" && k != "w_rid" {
+			query[k] = v
+		}
+	}
+	// Encode 会按照参数名排序
+	return u.Host + u.Path + "?" + query.Encode() + "#" + c.getCookie("DedeUserID"), ttl, true
+}
+
+// sharedFetchTimeout 是 ResponseCache 合并的请求的超时时间，包括重试和限流等待
+const sharedFetchTimeout = time.Minute
+
+// sharedFetch 是合并的请求的结果
+type sharedFetch struct {
+	body []byte
+	call *Call
+}
+
+// fetchCached 返回 key 对应的响应。命中缓存时直接返回，不经过限流器和重试；
+// 否则经过重试和限流器调用 fetch 获取响应，错误码为0时写入缓存。
+//
+// 相同 key 的并发调用只会发起一次请求。请求使用不随调用者取消的 context，每个调用者只等待到自己的 context 结束为止，
+// 请求的次数、状态码等信息会记录到每个调用者的 call 中。
+func (c *Client) fetchCached(call *Call, key string, ttl time.Duration, fetch func(c *Client) ([]byte, error)) ([]byte, error) {
+	rc := c.responseCache
+	if body, ok := rc.store.Get(key); ok {
+		call.Cached = true
+		return body, nil
+	}
+	ch := rc.sfg.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := detach(call.Context, sharedFetchTimeout)
+		defer cancel()
+		shared := &Call{Context: ctx, Endpoint: call.Endpoint, Method: call.Method, Url: call.Url}
+		c2 := c.WithContext(context.WithValue(ctx, callKey{}, shared))
+		var body []byte
+		err := c2.withRetry(shared, func() (err error) {
+			if body, err = fetch(c2); err != nil {
+				return err
+			}
+			return responseError(body)
+		})
+		if err == nil {
+			rc.store.Set(key, body, ttl)
+		}
+		return sharedFetch{body: body, call: shared}, err
+	})
+	select {
+	case <-c.Context().Done():
+		return nil, errors.WithStack(c.Context().Err())
+	case res := <-ch:
+		shared := res.Val.(sharedFetch)
+		call.Query = shared.call.Query
+		call.StatusCode = shared.call.StatusCode
+		call.Attempts = shared.call.Attempts
+		call.Proxy = shared.call.Proxy
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return shared.body, nil
+	}
+}
+
+// responseError 返回响应中的错误码对应的错误，错误码为0时返回 nil
+func responseError(body []byte) error {
+	var cr commonResp[json.RawMessage]
+	if err := json.Unmarshal(body, &cr); err != nil {
+		return errors.WithStack(err)
+	}
+	if cr.Code != 0 {
+		return errors.WithStack(Error{Code: cr.Code, Message: cr.Message})
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package bilibili_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := bilibili.NewMemoryCache(2, 10)
+	m.SetNow(func() time.Time { return now })
+
+	m.Set("a", []byte("1"), time.Minute)
+	m.Set("b", []byte("2"), time.Minute)
+	m.Get("a")
+	m.Set("c", []byte("3"), time.Minute)
+	if _, ok := m.Get("b"); ok || m.Len() != 2 {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	m.Set("d", []byte("123456789"), time.Minute)
+	if _, ok := m.Get("a"); ok || m.Len() != 2 {
+		t.Fatal("entries should be evicted when exceeding maxBytes")
+	}
+	m.Set("e", []byte("12345678901"), time.Minute)
+	if _, ok := m.Get("e"); ok {
+		t.Fatal("value larger than maxBytes should not be cached")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := m.Get("d"); ok || m.Len() != 1 {
+		t.Fatal("expired entry should be removed")
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const zonePath = "/x/web-interface/zone"
+	var once sync.Once
+	release := make(chan struct{})
+	server.Handle(zonePath, func(http.ResponseWriter, *http.Request) (any, error) {
+		once.Do(func() { <-release })
+		return bilibili.ZoneLocation{Addr: "127.0.0.1"}, nil
+	})
+
+	var cached []bool
+	var mu sync.Mutex
+	c := server.NewClient().
+		SetResponseCache(bilibili.NewResponseCache(bilibili.NewMemoryCache(100, 0)).SetEndpointTTL(zonePath, time.Minute)).
+		Use(func(next bilibili.Invoker) bilibili.Invoker {
+			return func(call *bilibili.Call) error {
+				err := next(call)
+				mu.Lock()
+				cached = append(cached, call.Cached)
+				mu.Unlock()
+				return err
+			}
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if zone, err := c.GetZoneLocation(); err != nil || zone.Addr != "127.0.0.1" {
+				t.Error("GetZoneLocation result not correct ", zone, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if count := server.RequestCount(zonePath); count != 1 {
+		t.Fatal("concurrent identical requests should be sent once, got ", count)
+	}
+
+	if _, err := c.GetZoneLocation(); err != nil || server.RequestCount(zonePath) != 1 || !cached[len(cached)-1] {
+		t.Fatal("response should be cached ", err, server.RequestCount(zonePath), cached)
+	}
+
+	c.SetRawCookies("DedeUserID=2")
+	if _, err := c.GetZoneLocation(); err != nil || server.RequestCount(zonePath) != 2 {
+		t.Fatal("responses should not be shared between users ", err, server.RequestCount(zonePath))
+	}
+
+	const viewPath = "/x/web-interface/view"
+	for i := 1; i <= 2; i++ {
+		if _, err := c.GetVideoInfo(bilibili.VideoParam{Aid: 170001}); err != nil || server.RequestCount(viewPath) != i {
+			t.Fatal("endpoints without ttl should not be cached", err, server.RequestCount(viewPath))
+		}
+	}
+}
+
+func TestResponseCacheRateLimit(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const zonePath = "/x/web-interface/zone"
+
+	c := server.NewClient().
+		SetResponseCache(bilibili.NewResponseCache(bilibili.NewMemoryCache(100, 0)).SetEndpointTTL(zonePath, time.Minute)).
+		SetRateLimiter(bilibili.NewRateLimiter().SetEndpointLimit(zonePath, bilibili.Every(time.Hour, 1)).SetFailFast(true))
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetZoneLocation(); err != nil {
+			t.Fatal("cached responses should not consume rate limit tokens ", i, err)
+		}
+	}
+	if count := server.RequestCount(zonePath); count != 1 {
+		t.Fatal("request count ", count)
+	}
+}
+
+func TestResponseCacheCancel(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const zonePath = "/x/web-interface/zone"
+	release := make(chan struct{})
+	server.Handle(zonePath, func(http.ResponseWriter, *http.Request) (any, error) {
+		<-release
+		return bilibili.ZoneLocation{Addr: "127.0.0.1"}, nil
+	})
+	c := server.NewClient().
+		SetResponseCache(bilibili.NewResponseCache(bilibili.NewMemoryCache(100, 0)).SetEndpointTTL(zonePath, time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.WithContext(ctx).GetZoneLocation()
+		leaderErr <- err
+	}()
+	for server.RequestCount(zonePath) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var attempts int
+	followerErr := make(chan error, 1)
+	go func() {
+		_, err := c.Use(func(next bilibili.Invoker) bilibili.Invoker {
+			return func(call *bilibili.Call) error {
+				err := next(call)
+				attempts = call.Attempts
+				return err
+			}
+		}).GetZoneLocation()
+		followerErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 发起请求的调用被取消，不影响等待同一响应的调用
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got ", err)
+	}
+	close(release)
+	if err := <-followerErr; err != nil || attempts != 1 {
+		t.Fatal("follower should get the shared response ", err, attempts)
+	}
+	if count := server.RequestCount(zonePath); count != 1 {
+		t.Fatal("request count ", count)
+	}
+	if _, err := c.GetZoneLocation(); err != nil || server.RequestCount(zonePath) != 1 {
+		t.Fatal("shared response should be cached ", err)
+	}
+}
@@ -1,7 +1,9 @@
 package bilibili
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -11,6 +13,22 @@ import (
 type Client struct {
 	wbi   *WBI
 	resty *resty.Client
+	hosts *hostRegistry
+	ctx   context.Context
+
+	cookies *cookieJar
+
+	session     *sessionState
+	retryPolicy *RetryPolicy
+	rateLimiter *RateLimiter
+	middlewares []Middleware
+
+	responseCache *ResponseCache
+	proxyPool     *ProxyPool
+	directProxy   func(*http.Request) (*url.URL, error) // 设置代理池之前 Transport 的 Proxy，取消代理池时恢复
+
+	rawData    *RawData
+	decodeMode DecodeMode
 }
 
 // New 返回一个默认的 bilibili.Client
@@ -27,22 +45,78 @@ func New() *Client {
 }
 
 // NewWithClient 接收一个自定义的*resty.Client为参数
+//
+// cookies 由 Client 自己管理，restyClient 原有的 Cookies 会被移入 Client，它的 CookieJar 会被清空，以免重复携带cookie。
 func NewWithClient(restyClient *resty.Client) *Client {
-	return &Client{
+	c := &Client{
 		wbi:   NewDefaultWbi(),
 		resty: restyClient,
+		hosts: &hostRegistry{},
+
+		cookies: newCookieJar(),
+		session: &sessionState{},
+	}
+	c.wbi.client = c
+	for _, cookie := range restyClient.Cookies {
+		c.cookies.set(cookie)
 	}
+	restyClient.Cookies = nil
+	restyClient.SetCookieJar(nil)
+	return c
 }
 
 func (c *Client) Resty() *resty.Client {
 	return c.resty
 }
 
+// Wbi 返回 Client 使用的 WBI 签名实现，可以用它修改 wbi keys 的储存、过期时间等设置
+func (c *Client) Wbi() *WBI {
+	return c.wbi
+}
+
+// WithContext 返回一个绑定了 ctx 的 Client 浅拷贝，通过它发起的所有请求都会在 ctx 取消或超时后中止。
+//
+// 返回的 Client 与原 Client 共享 cookies、WBI 等全部状态，可以按请求随用随建，例如：
+//
+//	info, err := client.WithContext(ctx).GetVideoInfo(bilibili.VideoParam{Bvid: "BV1xx411c7mD"})
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// Context 返回 Client 绑定的 context，未绑定时返回 context.Background()
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// detachedContext 保留 parent 中的值，但不随 parent 取消，相当于 Go 1.21 的 context.WithoutCancel
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (d detachedContext) Value(key any) any         { return d.parent.Value(key) }
+
+// detach 返回一个保留 ctx 中的值、不随 ctx 取消、timeout 后超时的 context。
+// 用于多个调用者共享的请求，以免其中一个调用者取消时其它调用者也失败
+func detach(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(detachedContext{parent: ctx}, timeout)
+}
+
 // GetCookiesString 获取字符串格式的cookies，方便自行存储后下次使用。配合下面的 SetCookiesString 使用。
 func (c *Client) GetCookiesString() string {
-	cookies := c.resty.Cookies
+	cookies := c.GetCookies()
 	cookieStrings := make([]string, 0, len(cookies))
-	for _, cookie := range c.resty.Cookies {
+	for _, cookie := range cookies {
 		cookieStrings = append(cookieStrings, cookie.String())
 	}
 	return strings.Join(cookieStrings, "\n")
@@ -64,15 +138,10 @@ func (c *Client) SetRawCookies(rawCookies string) {
 	c.SetCookies(req.Cookies())
 }
 
-// SetCookie 设置单个cookie
+// SetCookie 设置单个cookie。没有指定 Domain 时对B站的所有域名生效，没有指定 Path 时对所有路径生效。
+// name、domain、path 都相同的cookie会被替换。
 func (c *Client) SetCookie(cookie *http.Cookie) {
-	for i, c0 := range c.resty.Cookies {
-		if c0.Name == cookie.Name {
-			c.resty.Cookies[i] = cookie
-			return
-		}
-	}
-	c.resty.Cookies = append(c.resty.Cookies, cookie)
+	c.cookies.set(cookie)
 }
 
 // SetCookies 设置cookies
@@ -82,19 +151,41 @@ func (c *Client) SetCookies(cookies []*http.Cookie) {
 	}
 }
 
-// GetCookies 获取当前的cookies
+// GetCookies 获取当前的cookies，返回的是拷贝，修改它们不会影响 Client
 func (c *Client) GetCookies() []*http.Cookie {
-	return c.resty.Cookies
+	return c.cookies.all()
+}
+
+// CookieJar 返回 Client 使用的 http.CookieJar，它是并发安全的，可以设置给其它的 http.Client 共享登录状态
+func (c *Client) CookieJar() http.CookieJar {
+	return c.cookies
 }
 
 // 根据key获取指定的cookie值
 func (c *Client) getCookie(name string) string {
-	now := time.Now()
-	// 查找指定name的cookie
-	for _, cookie := range c.resty.Cookies {
-		if cookie.Name == name && (cookie.Expires.IsZero() || cookie.Expires.After(now)) {
-			return cookie.Value
-		}
+	return c.lookupCookie(name, false)
+}
+
+// lookupCookie 获取指定name的cookie值，allowExpired 为 true 时也会返回已经过期的cookie
+func (c *Client) lookupCookie(name string, allowExpired bool) string {
+	return c.cookies.get(name, allowExpired)
+}
+
+// newRequest 创建一个请求 rawUrl 的 *resty.Request，带上 c 的 context 和 rawUrl 对应的cookies
+func (c *Client) newRequest(rawUrl string) *resty.Request {
+	r := c.resty.R().SetContext(c.Context())
+	c.attachCookies(r, rawUrl)
+	return r
+}
+
+// attachCookies 为请求带上 rawUrl 对应的cookies，r.Cookies 已经被设置时什么也不做。
+//
+// rawUrl 是B站原本的地址，而不是经过 SetHost 改写后的地址，这样cookie的 Domain 才能正确匹配。
+func (c *Client) attachCookies(r *resty.Request, rawUrl string) {
+	if len(r.Cookies) > 0 {
+		return
+	}
+	if u, err := url.Parse(rawUrl); err == nil {
+		r.SetCookies(c.cookies.Cookies(u))
 	}
-	return ""
 }
@@ -1,9 +1,14 @@
 package bilibili
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func deepEquals(a, b []*http.Cookie) bool {
@@ -58,3 +63,30 @@ func TestCookie(t *testing.T) {
 		}
 	}
 }
+
+func TestWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := New()
+	if c.Context() != context.Background() {
+		t.Fatal("default context should be context.Background()")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c2 := c.WithContext(ctx)
+	if c2.Context() != ctx || c.Context() != context.Background() {
+		t.Fatal("WithContext should not modify the original client")
+	}
+
+	_, err := execute[any](c2, http.MethodGet, server.URL, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected context.DeadlineExceeded, got ", err)
+	}
+}
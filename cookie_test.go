@@ -0,0 +1,47 @@
+package bilibili_test
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestConcurrentCookies(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var n int64
+	var mu sync.Mutex
+	server.Handle("/x/web-interface/zone", func(w http.ResponseWriter, _ *http.Request) (any, error) {
+		mu.Lock()
+		n++
+		value := strconv.FormatInt(n, 10)
+		mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "buvid3", Value: value, Domain: "bilibili.com", Path: "/"})
+		return bilibili.ZoneLocation{Addr: "127.0.0.1"}, nil
+	})
+
+	c := server.NewClient()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := c.GetZoneLocation(); err != nil {
+					t.Error(err)
+					return
+				}
+				c.SetRawCookies("a=1")
+				_ = c.GetCookiesString()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(c.GetCookies()) != 2 || len(cookieValue(c, "buvid3")) == 0 {
+		t.Fatal("cookies not correct ", c.GetCookiesString())
+	}
+}
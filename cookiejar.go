@@ -0,0 +1,209 @@
+package bilibili
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCookieDomain 是没有指定 Domain 的cookie默认生效的域名，即B站的所有子域名
+const defaultCookieDomain = "bilibili.com"
+
+// cookieJar 是并发安全的 http.CookieJar，按照 domain、path、过期时间决定一个请求需要携带哪些cookie。
+//
+// 与 net/http/cookiejar 不同的是，它可以列出全部的cookie（包括 Expires 等属性），方便持久化；
+// 并且会保留通过 Client.SetCookie 设置的已经过期的cookie，刷新cookies时仍然需要它们。
+type cookieJar struct {
+	mu      sync.Mutex
+	entries []*jarEntry // 按照设置的先后顺序排列
+	now     func() time.Time
+}
+
+type jarEntry struct {
+	cookie   *http.Cookie // 原始的cookie，GetCookies 和 GetCookiesString 返回的就是它
+	domain   string       // 生效的域名，不含开头的"."
+	hostOnly bool         // 为 true 时只对 domain 本身生效，不对子域名生效
+	path     string       // 生效的路径
+	expires  time.Time    // 过期时间，为零值表示会话cookie
+}
+
+func newCookieJar() *cookieJar {
+	return &cookieJar{now: time.Now}
+}
+
+// SetCookies 实现 http.CookieJar，保存 u 的响应中的cookies。Domain 与 u 不匹配的cookie会被忽略，已经过期的cookie会被删除
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Hostname())
+	now := j.now()
+	for _, cookie := range cookies {
+		e := &jarEntry{cookie: copyCookie(cookie), domain: host, hostOnly: true, path: cookie.Path}
+		if len(cookie.Domain) > 0 {
+			e.domain, e.hostOnly = canonicalHost(cookie.Domain), false
+			if !domainMatch(host, e.domain) {
+				continue
+			}
+		} else {
+			e.cookie.Domain = host
+		}
+		if len(e.path) == 0 || e.path[0] != '/' {
+			e.path = defaultPath(u.Path)
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			j.remove(e)
+			continue
+		case cookie.MaxAge > 0:
+			e.expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			e.cookie.Expires, e.cookie.MaxAge = e.expires, 0
+		default:
+			e.expires = cookie.Expires
+		}
+		if !e.expires.IsZero() && !e.expires.After(now) {
+			j.remove(e)
+			continue
+		}
+		j.put(e)
+	}
+}
+
+// Cookies 实现 http.CookieJar，返回请求 u 时需要携带的cookies
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.cookiesFor(u, false)
+}
+
+// cookiesFor 返回请求 u 时需要携带的cookies，includeExpired 为 true 时也会返回已经过期的cookie
+func (j *cookieJar) cookiesFor(u *url.URL, includeExpired bool) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := canonicalHost(u.Hostname())
+	path := u.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	now := j.now()
+	matched := make([]*jarEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if e.hostOnly && host != e.domain || !e.hostOnly && !domainMatch(host, e.domain) {
+			continue
+		}
+		if !pathMatch(path, e.path) || e.cookie.Secure && u.Scheme != "https" {
+			continue
+		}
+		if !includeExpired && !e.expires.IsZero() && !e.expires.After(now) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	// 路径更长的cookie排在前面
+	sort.SliceStable(matched, func(a, b int) bool {
+		return len(matched[a].path) > len(matched[b].path)
+	})
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, e := range matched {
+		cookies = append(cookies, &http.Cookie{Name: e.cookie.Name, Value: e.cookie.Value})
+	}
+	return cookies
+}
+
+// set 直接设置一个cookie，没有指定 Domain 时对B站的所有子域名生效，没有指定 Path 时对所有路径生效
+func (j *cookieJar) set(cookie *http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e := &jarEntry{cookie: copyCookie(cookie), domain: defaultCookieDomain, path: cookie.Path, expires: cookie.Expires}
+	if len(cookie.Domain) > 0 {
+		e.domain = canonicalHost(cookie.Domain)
+	}
+	if len(e.path) == 0 || e.path[0] != '/' {
+		e.path = "/"
+	}
+	j.put(e)
+}
+
+// all 返回全部cookie的拷贝，包括已经过期的
+func (j *cookieJar) all() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cookies := make([]*http.Cookie, 0, len(j.entries))
+	for _, e := range j.entries {
+		cookies = append(cookies, copyCookie(e.cookie))
+	}
+	return cookies
+}
+
+// get 返回名为 name 的cookie的值，allowExpired 为 true 时也会返回已经过期的cookie
+func (j *cookieJar) get(name string, allowExpired bool) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	for _, e := range j.entries {
+		if e.cookie.Name == name && (allowExpired || e.expires.IsZero() || e.expires.After(now)) {
+			return e.cookie.Value
+		}
+	}
+	return ""
+}
+
+// put 添加或替换 name、domain、path 都相同的cookie，替换时保持原来的顺序
+func (j *cookieJar) put(e *jarEntry) {
+	for i, e0 := range j.entries {
+		if e0.same(e) {
+			j.entries[i] = e
+			return
+		}
+	}
+	j.entries = append(j.entries, e)
+}
+
+func (j *cookieJar) remove(e *jarEntry) {
+	for i, e0 := range j.entries {
+		if e0.same(e) {
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *jarEntry) same(e2 *jarEntry) bool {
+	return e.cookie.Name == e2.cookie.Name && e.domain == e2.domain && e.path == e2.path
+}
+
+func copyCookie(cookie *http.Cookie) *http.Cookie {
+	cookie2 := *cookie
+	return &cookie2
+}
+
+func canonicalHost(host string) string {
+	return strings.ToLower(strings.TrimPrefix(host, "."))
+}
+
+// domainMatch 判断 host 是否是 domain 本身或者它的子域名
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch 判断请求路径 path 是否在cookie路径 cookiePath 之下
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultPath 返回响应没有指定 Path 时cookie的默认路径，即请求路径所在的目录
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
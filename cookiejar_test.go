@@ -0,0 +1,59 @@
+package bilibili
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func cookieNames(cookies []*http.Cookie) string {
+	s := ""
+	for _, cookie := range cookies {
+		s += cookie.Name + ";"
+	}
+	return s
+}
+
+func TestCookieJar(t *testing.T) {
+	jar := newCookieJar()
+	passport, _ := url.Parse("https://passport.bilibili.com/x/passport-login/web/qrcode/poll")
+	jar.SetCookies(passport, []*http.Cookie{
+		{Name: "SESSDATA", Value: "1", Domain: ".bilibili.com", Path: "/"},
+		{Name: "host", Value: "2", Path: "/"},
+		{Name: "other", Value: "3", Domain: "example.com"},
+		{Name: "secure", Value: "4", Path: "/x/passport-login", Secure: true},
+	})
+
+	cases := map[string]string{
+		"https://api.vc.bilibili.com/dynamic_svr/v1/dynamic_svr/space_history": "SESSDATA;",
+		"https://api.live.bilibili.com/room/v1/Area/getList":                   "SESSDATA;",
+		"https://passport.bilibili.com/x/passport-login/web/key":               "secure;SESSDATA;host;",
+		"http://passport.bilibili.com/x/passport-login/web/key":                "SESSDATA;host;",
+		"https://passport.bilibili.com/x/passport-loginx":                      "SESSDATA;host;",
+		"https://bilibili.com.cn/":                                             "",
+	}
+	for rawUrl, expected := range cases {
+		u, _ := url.Parse(rawUrl)
+		if actual := cookieNames(jar.Cookies(u)); actual != expected {
+			t.Fatalf("Cookies(%q) = %q, expected %q", rawUrl, actual, expected)
+		}
+	}
+
+	jar.SetCookies(passport, []*http.Cookie{
+		{Name: "SESSDATA", Value: "5", Domain: "bilibili.com", Path: "/", MaxAge: 60},
+		{Name: "host", Path: "/", Expires: time.Unix(1, 0)},
+	})
+	if jar.get("SESSDATA", false) != "5" || len(jar.get("host", true)) > 0 || len(jar.all()) != 2 {
+		t.Fatal("cookies should be replaced or deleted ", jar.all())
+	}
+
+	jar.set(&http.Cookie{Name: "bili_jct", Value: "6", Expires: time.Now().Add(-time.Hour)})
+	api, _ := url.Parse("https://api.bilibili.com/x/web-interface/nav")
+	if cookieNames(jar.Cookies(api)) != "SESSDATA;" || cookieNames(jar.cookiesFor(api, true)) != "SESSDATA;bili_jct;" {
+		t.Fatal("expired cookie should only be sent when includeExpired is set")
+	}
+	if len(jar.get("bili_jct", false)) > 0 || jar.get("bili_jct", true) != "6" {
+		t.Fatal("expired cookie lookup not correct")
+	}
+}
@@ -0,0 +1,456 @@
+package bilibili
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/pkg/errors"
+)
+
+// RawData 保存一次调用返回的原始 data 字段，用于读取结构体中没有定义的字段。使用 Client.WithRawData 获取。
+type RawData struct {
+	data json.RawMessage
+}
+
+// WithRawData 返回一个 Client 浅拷贝，通过它发起的调用在解析结果的同时，会把响应中原始的 data 字段保存到 raw 中。
+// 多次调用时 raw 中保存的是最后一次调用的结果，因此不要在多个 goroutine 中共享同一个 raw。例如：
+//
+//	var raw bilibili.RawData
+//	info, err := client.WithRawData(&raw).GetVideoInfo(bilibili.VideoParam{Bvid: "BV1xx411c7mD"})
+//	j, err := raw.Json()
+//	fmt.Println(info.Title, j.Get("new_field").MustString())
+func (c *Client) WithRawData(raw *RawData) *Client {
+	c2 := *c
+	c2.rawData = raw
+	return &c2
+}
+
+// Bytes 返回原始的 data 字段，接口没有返回 data 时为空
+func (r *RawData) Bytes() json.RawMessage {
+	return r.data
+}
+
+// Json 将原始的 data 字段解析为 *simplejson.Json
+func (r *RawData) Json() (*simplejson.Json, error) {
+	if len(r.data) == 0 {
+		return simplejson.New(), nil
+	}
+	j, err := simplejson.NewJson(r.data)
+	return j, errors.WithStack(err)
+}
+
+// Unmarshal 将原始的 data 字段解析到 v 中
+func (r *RawData) Unmarshal(v any) error {
+	if len(r.data) == 0 {
+		return nil
+	}
+	return errors.WithStack(json.Unmarshal(r.data, v))
+}
+
+// DecodeMode 是解析接口返回的 data 字段的方式，使用 Client.SetDecodeMode 设置
+type DecodeMode int
+
+const (
+	DecodeDefault  DecodeMode = iota // 与 encoding/json 相同：忽略未定义的字段，有字段类型不符时整个调用失败
+	DecodeStrict                     // 检查全部未定义的字段和类型不符的字段，有任何一处时返回 DecodeError，适合在CI中对照录制的响应使用
+	DecodeTolerant                   // 数字形式的字符串和字符串形式的数字会被转换为需要的类型，仍然不符的字段保持零值，不会因此失败
+)
+
+// DecodeIssueKind 是响应与结构体定义不符的类型
+type DecodeIssueKind int
+
+const (
+	UnknownField DecodeIssueKind = iota // 响应中有结构体没有定义的字段，只在 DecodeStrict 模式下记录
+	TypeMismatch                        // 字段的类型与响应不符，字段保持零值
+	TypeCoerced                         // 字段的类型与响应不符，但在 DecodeTolerant 模式下转换成功
+)
+
+func (k DecodeIssueKind) String() string {
+	switch k {
+	case UnknownField:
+		return "unknown field"
+	case TypeMismatch:
+		return "type mismatch"
+	case TypeCoerced:
+		return "type coerced"
+	default:
+		return "unknown"
+	}
+}
+
+// DecodeIssue 是响应中一处与结构体定义不符的地方
+type DecodeIssue struct {
+	Path     string          // 在 data 字段中的路径，例如 "owner.mid"、"pages[0].cid"，data 字段本身为空
+	Kind     DecodeIssueKind // 不符的类型
+	Expected string          // 结构体中定义的类型，例如 "int"。UnknownField 时为空
+	Actual   string          // 响应中的类型：string、number、bool、object、array
+}
+
+func (i DecodeIssue) String() string {
+	if i.Kind == UnknownField {
+		return fmt.Sprintf("%s: %s (%s)", i.Path, i.Kind, i.Actual)
+	}
+	return fmt.Sprintf("%s: %s, expected %s, got %s", i.Path, i.Kind, i.Expected, i.Actual)
+}
+
+// DecodeError 表示 DecodeStrict 模式下响应与结构体定义不符
+type DecodeError struct {
+	Issues []DecodeIssue
+}
+
+func (e DecodeError) Error() string {
+	issues := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		issues = append(issues, issue.String())
+	}
+	return fmt.Sprintf("响应与定义不符: %s", strings.Join(issues, "; "))
+}
+
+// SetDecodeMode 设置解析接口返回的 data 字段的方式，默认为 DecodeDefault。
+//
+// DecodeStrict 和 DecodeTolerant 模式下发现的问题会记录在 Call.DecodeIssues 中，可以通过中间件记录下来。
+func (c *Client) SetDecodeMode(mode DecodeMode) *Client {
+	c.decodeMode = mode
+	return c
+}
+
+// Decode 按照 mode 把 data 解析到 v 中，返回与 v 的定义不符的地方。
+// DecodeStrict 模式下有不符的地方时返回 DecodeError，此时 v 中仍然是尽量解析的结果。
+// 可以用于检查录制的响应，例如：
+//
+//	var info bilibili.VideoDetailInfo
+//	issues, err := bilibili.Decode(fixture, &info, bilibili.DecodeStrict)
+func Decode(data []byte, v any, mode DecodeMode) ([]DecodeIssue, error) {
+	if mode == DecodeDefault {
+		return nil, errors.WithStack(json.Unmarshal(data, v))
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, errors.WithStack(ErrInvalidParam)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d := &decoder{mode: mode}
+	value = d.normalize("", value, rv.Type().Elem())
+	// 对象的字段是无序遍历的，按照路径排序使结果稳定
+	sort.SliceStable(d.issues, func(i, j int) bool { return d.issues[i].Path < d.issues[j].Path })
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return d.issues, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(normalized, v); err != nil {
+		return d.issues, errors.WithStack(err)
+	}
+	if mode == DecodeStrict && len(d.issues) > 0 {
+		return d.issues, errors.WithStack(DecodeError{Issues: d.issues})
+	}
+	return d.issues, nil
+}
+
+// decodeResponse 解析接口返回的内容，错误码不为0时返回 Error。c 设置了 RawData 时会同时保存原始的 data 字段
+func decodeResponse[Out any](c *Client, body []byte) (out Out, err error) {
+	if c.rawData == nil && c.decodeMode == DecodeDefault {
+		var cr commonResp[Out]
+		if err = json.Unmarshal(body, &cr); err != nil {
+			return out, errors.WithStack(err)
+		}
+		if cr.Code != 0 {
+			return out, errors.WithStack(Error{Code: cr.Code, Message: cr.Message})
+		}
+		return cr.Data, nil
+	}
+	var cr commonResp[json.RawMessage]
+	if err = json.Unmarshal(body, &cr); err != nil {
+		return out, errors.WithStack(err)
+	}
+	if c.rawData != nil {
+		c.rawData.data = cr.Data
+	}
+	if cr.Code != 0 {
+		return out, errors.WithStack(Error{Code: cr.Code, Message: cr.Message})
+	}
+	if len(cr.Data) == 0 || bytes.Equal(cr.Data, []byte("null")) {
+		return out, nil
+	}
+	issues, err := Decode(cr.Data, &out, c.decodeMode)
+	if call := callFromContext(c.Context()); call != nil {
+		call.DecodeIssues = issues
+	}
+	return out, err
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decoder 对照结构体定义检查 UseNumber 解析出的值
+type decoder struct {
+	mode   DecodeMode
+	issues []DecodeIssue
+}
+
+// normalize 检查 v 能否解析为 t 类型，返回可以交给 json.Unmarshal 的值：
+// DecodeTolerant 模式下会转换类型，无法解析的值会被替换为 nil，使字段保持零值
+func (d *decoder) normalize(path string, v any, t reflect.Type) any {
+	if v == nil || t.Kind() == reflect.Interface {
+		return v
+	}
+	if t.Kind() != reflect.Pointer && (t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType)) {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return d.normalize(path, v, t.Elem())
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return d.mismatch(path, v, t)
+		}
+		fields := typeFields(t)
+		for key, value := range obj {
+			f, ok := fields.lookup(key)
+			if !ok {
+				if d.mode == DecodeStrict {
+					d.issues = append(d.issues, DecodeIssue{Path: joinPath(path, key), Kind: UnknownField, Actual: jsonType(value)})
+				}
+				continue
+			}
+			if !f.quoted {
+				obj[key] = d.normalize(joinPath(path, key), value, f.typ)
+			}
+		}
+		return obj
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return d.mismatch(path, v, t)
+		}
+		for key, value := range obj {
+			obj[key] = d.normalize(joinPath(path, key), value, t.Elem())
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// []byte 是 base64 编码的字符串
+			if _, ok := v.(string); ok {
+				return v
+			}
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return d.mismatch(path, v, t)
+		}
+		for i := range arr {
+			arr[i] = d.normalize(fmt.Sprintf("%s[%d]", path, i), arr[i], t.Elem())
+		}
+		return arr
+	case reflect.String:
+		switch x := v.(type) {
+		case string:
+			return v
+		case json.Number:
+			return d.coerce(path, v, t, x.String())
+		case bool:
+			return d.coerce(path, v, t, strconv.FormatBool(x))
+		}
+	case reflect.Bool:
+		switch x := v.(type) {
+		case bool:
+			return v
+		case json.Number:
+			if f, err := x.Float64(); err == nil {
+				return d.coerce(path, v, t, f != 0)
+			}
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(x)); err == nil {
+				return d.coerce(path, v, t, b)
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := parseNumber(v); ok {
+			if i, err := strconv.ParseInt(n, 10, 64); err == nil && !reflect.Zero(t).OverflowInt(i) {
+				return d.coerceNumber(path, v, t, n)
+			}
+			if f, err := strconv.ParseFloat(n, 64); err == nil && f == math.Trunc(f) && !reflect.Zero(t).OverflowInt(int64(f)) {
+				return d.coerce(path, v, t, json.Number(strconv.FormatInt(int64(f), 10)))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := parseNumber(v); ok {
+			if u, err := strconv.ParseUint(n, 10, 64); err == nil && !reflect.Zero(t).OverflowUint(u) {
+				return d.coerceNumber(path, v, t, n)
+			}
+			if f, err := strconv.ParseFloat(n, 64); err == nil && f >= 0 && f == math.Trunc(f) && !reflect.Zero(t).OverflowUint(uint64(f)) {
+				return d.coerce(path, v, t, json.Number(strconv.FormatUint(uint64(f), 10)))
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := parseNumber(v); ok {
+			if f, err := strconv.ParseFloat(n, 64); err == nil && !reflect.Zero(t).OverflowFloat(f) {
+				return d.coerceNumber(path, v, t, n)
+			}
+		}
+	default:
+		return v
+	}
+	return d.mismatch(path, v, t)
+}
+
+// coerceNumber 在 v 已经是数字时原样返回，否则转换为数字 n
+func (d *decoder) coerceNumber(path string, v any, t reflect.Type, n string) any {
+	if _, ok := v.(json.Number); ok {
+		return v
+	}
+	return d.coerce(path, v, t, json.Number(n))
+}
+
+// coerce 在 DecodeTolerant 模式下把 v 转换为 converted，其它模式下视为类型不符
+func (d *decoder) coerce(path string, v any, t reflect.Type, converted any) any {
+	if d.mode != DecodeTolerant {
+		return d.mismatch(path, v, t)
+	}
+	d.issues = append(d.issues, DecodeIssue{Path: path, Kind: TypeCoerced, Expected: t.String(), Actual: jsonType(v)})
+	return converted
+}
+
+func (d *decoder) mismatch(path string, v any, t reflect.Type) any {
+	d.issues = append(d.issues, DecodeIssue{Path: path, Kind: TypeMismatch, Expected: t.String(), Actual: jsonType(v)})
+	return nil
+}
+
+// parseNumber 返回数字或者数字形式的字符串的文本，bool 视为0和1，空字符串视为0
+func parseNumber(v any) (string, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.String(), true
+	case string:
+		x = strings.TrimSpace(x)
+		if len(x) == 0 {
+			return "0", true
+		}
+		return x, true
+	case bool:
+		if x {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+type decodeField struct {
+	typ    reflect.Type
+	quoted bool // 有 ",string" 选项，由 encoding/json 自行处理
+}
+
+// decodeFields 是 encoding/json 解析一个结构体时使用的字段
+type decodeFields struct {
+	byName map[string]decodeField
+	names  []string
+}
+
+// lookup 与 encoding/json 相同，优先精确匹配字段名，其次不区分大小写匹配
+func (f *decodeFields) lookup(key string) (decodeField, bool) {
+	if field, ok := f.byName[key]; ok {
+		return field, true
+	}
+	for _, name := range f.names {
+		if strings.EqualFold(name, key) {
+			return f.byName[name], true
+		}
+	}
+	return decodeField{}, false
+}
+
+var fieldsCache sync.Map // reflect.Type -> *decodeFields
+
+func typeFields(t reflect.Type) *decodeFields {
+	if f, ok := fieldsCache.Load(t); ok {
+		return f.(*decodeFields)
+	}
+	fields := &decodeFields{byName: make(map[string]decodeField, t.NumField())}
+	collectFields(t, fields, map[reflect.Type]bool{})
+	f, _ := fieldsCache.LoadOrStore(t, fields)
+	return f.(*decodeFields)
+}
+
+// collectFields 收集 t 的字段，包括嵌入的结构体的字段，层级浅的字段优先
+func collectFields(t reflect.Type, fields *decodeFields, visited map[reflect.Type]bool) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && len(name) == 0 {
+			et := sf.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				embedded = append(embedded, et)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = sf.Name
+		}
+		if _, ok := fields.byName[name]; ok {
+			continue
+		}
+		quoted := false
+		for _, opt := range strings.Split(opts, ",") {
+			quoted = quoted || opt == "string"
+		}
+		fields.byName[name] = decodeField{typ: sf.Type, quoted: quoted}
+		fields.names = append(fields.names, name)
+	}
+	for _, et := range embedded {
+		collectFields(et, fields, visited)
+	}
+}
@@ -0,0 +1,149 @@
+package bilibili_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestWithRawData(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.HandleFixture("/x/web-interface/zone", []byte(`{"addr":"127.0.0.1","country":"中国","new_field":{"x":1}}`))
+	c := server.NewClient()
+
+	var raw bilibili.RawData
+	zone, err := c.WithRawData(&raw).GetZoneLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Addr != "127.0.0.1" || zone.Country != "中国" {
+		t.Fatal("typed result not correct ", zone)
+	}
+	j, err := raw.Json()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Get("new_field").Get("x").MustInt() != 1 {
+		t.Fatal("raw data not correct ", string(raw.Bytes()))
+	}
+	var extra struct {
+		NewField struct {
+			X int `json:"x"`
+		} `json:"new_field"`
+	}
+	if err = raw.Unmarshal(&extra); err != nil || extra.NewField.X != 1 {
+		t.Fatal("Unmarshal failed ", err)
+	}
+
+	// 返回错误码时也会保存原始的 data 字段
+	server.HandleError("/x/web-interface/zone", -404, "啥都木有")
+	if _, err = c.WithRawData(&raw).GetZoneLocation(); !bilibili.IsNotFound(err) {
+		t.Fatal("expected -404, got ", err)
+	}
+	if string(raw.Bytes()) != "null" {
+		t.Fatal("raw data should be replaced ", string(raw.Bytes()))
+	}
+}
+
+func TestDecodeModes(t *testing.T) {
+	type Owner struct {
+		Mid  int    `json:"mid"`
+		Name string `json:"name"`
+	}
+	type Base struct {
+		Bvid string `json:"bvid"`
+	}
+	type Video struct {
+		Base
+		Owner  *Owner   `json:"owner"`
+		Play   int      `json:"play"`
+		Tags   []string `json:"tags"`
+		Online bool     `json:"online"`
+		Extra  any      `json:"extra"`
+	}
+	data := []byte(`{"bvid":"BV1xx411c7mD","owner":{"mid":"2","name":"碧诗","level":6},"play":"1.2万","tags":["a",1],"online":1,"extra":{"x":1}}`)
+
+	var v Video
+	if err := json.Unmarshal(data, &v); err == nil {
+		t.Fatal("encoding/json should fail")
+	}
+
+	v = Video{}
+	issues, err := bilibili.Decode(data, &v, bilibili.DecodeStrict)
+	var de bilibili.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatal("expected bilibili.DecodeError, got ", err)
+	}
+	found := map[string]bilibili.DecodeIssueKind{}
+	for _, issue := range issues {
+		found[issue.Path] = issue.Kind
+	}
+	expected := map[string]bilibili.DecodeIssueKind{
+		"owner.mid":   bilibili.TypeMismatch,
+		"owner.level": bilibili.UnknownField,
+		"play":        bilibili.TypeMismatch,
+		"tags[1]":     bilibili.TypeMismatch,
+		"online":      bilibili.TypeMismatch,
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatal("strict issues not correct ", issues)
+	}
+	if v.Bvid != "BV1xx411c7mD" || v.Owner.Name != "碧诗" {
+		t.Fatal("strict mode should still decode the valid fields ", v)
+	}
+
+	v = Video{}
+	issues, err = bilibili.Decode(data, &v, bilibili.DecodeTolerant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Owner.Mid != 2 || v.Play != 0 || v.Tags[1] != "1" || !v.Online {
+		t.Fatal("tolerant result not correct ", v)
+	}
+	found = map[string]bilibili.DecodeIssueKind{}
+	for _, issue := range issues {
+		found[issue.Path] = issue.Kind
+	}
+	expected = map[string]bilibili.DecodeIssueKind{
+		"owner.mid": bilibili.TypeCoerced,
+		"play":      bilibili.TypeMismatch,
+		"tags[1]":   bilibili.TypeCoerced,
+		"online":    bilibili.TypeCoerced,
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatal("tolerant issues not correct ", issues)
+	}
+}
+
+func TestClientDecodeMode(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.HandleFixture("/x/web-interface/zone", []byte(`{"addr":"127.0.0.1","zone_id":"123"}`))
+
+	var issues []bilibili.DecodeIssue
+	c := server.NewClient().Use(func(next bilibili.Invoker) bilibili.Invoker {
+		return func(call *bilibili.Call) error {
+			err := next(call)
+			issues = call.DecodeIssues
+			return err
+		}
+	})
+	if _, err := c.GetZoneLocation(); err == nil {
+		t.Fatal("default mode should fail")
+	}
+	zone, err := c.SetDecodeMode(bilibili.DecodeTolerant).GetZoneLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.ZoneId != 123 || len(issues) != 1 || issues[0].Path != "zone_id" {
+		t.Fatal("tolerant mode not correct ", zone, issues)
+	}
+	if _, err = c.SetDecodeMode(bilibili.DecodeStrict).GetZoneLocation(); !errors.As(err, &bilibili.DecodeError{}) {
+		t.Fatal("expected bilibili.DecodeError, got ", err)
+	}
+}
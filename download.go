@@ -0,0 +1,472 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultChunkSize   = 4 << 20
+	defaultConcurrency = 4
+	maxUrlRefreshes    = 2 // 一个流最多重新获取地址的次数
+)
+
+var regContentRange = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// DownloadTrack 是一个要下载的 DASH 流和保存的路径
+type DownloadTrack struct {
+	Stream *AudioOrVideo // GetVideoStream 返回的视频流或音频流
+	Path   string        // 保存的路径，下载过程中使用 Path+".part" 和 Path+".part.json" 保存未完成的文件和进度
+}
+
+// DownloadProgress 是下载进度
+type DownloadProgress struct {
+	Path       string // 正在下载的 DownloadTrack.Path
+	Downloaded int64  // 已经下载的字节数，包括上次下载中断前已经完成的部分
+	Total      int64  // 文件的总字节数
+}
+
+// Downloader 下载 GetVideoStream 返回的 DASH 流（m4s 文件）。使用 Client.NewDownloader 创建。
+//
+// 每个文件被分为若干块，使用 HTTP Range 请求并行下载。下载中断后再次下载同一个文件会跳过已经完成的块。
+// 一个地址失败时会依次换用 BaseUrl 和 BackupUrl 中的地址，地址过期（B站的流地址有效时间为120分钟）或者全部失败时会重新请求 GetVideoStream。
+// 请求使用与 Client 的 *resty.Client 相同的 Transport、请求头和超时设置，因此会带上B站的 Referer，但是会跟随 CDN 的跳转；
+// 每个请求都会经过 Client 的中间件、重试策略、限流器和代理池。
+type Downloader struct {
+	client      *Client
+	resty       *resty.Client
+	chunkSize   int64
+	concurrency int
+	onProgress  func(DownloadProgress)
+	progressMu  sync.Mutex
+}
+
+// NewDownloader 创建一个 Downloader，下载时使用 c.Context() 作为 context
+func (c *Client) NewDownloader() *Downloader {
+	return &Downloader{client: c, resty: c.downloadClient(), chunkSize: defaultChunkSize, concurrency: defaultConcurrency}
+}
+
+// maxDownloadRedirects 是下载时最多跟随的跳转次数
+const maxDownloadRedirects = 10
+
+// downloadClient 返回下载使用的 *resty.Client。c.resty 不跟随跳转，而 CDN 可能会把请求 302 到其它节点
+func (c *Client) downloadClient() *resty.Client {
+	hc := c.resty.GetClient()
+	rc := resty.NewWithClient(&http.Client{Transport: hc.Transport, Timeout: hc.Timeout}).
+		SetRedirectPolicy(resty.FlexibleRedirectPolicy(maxDownloadRedirects))
+	rc.Header = c.resty.Header.Clone()
+	return rc
+}
+
+// SetChunkSize 设置每个 Range 请求的字节数，默认为4MB
+func (d *Downloader) SetChunkSize(size int64) *Downloader {
+	if size > 0 {
+		d.chunkSize = size
+	}
+	return d
+}
+
+// SetConcurrency 设置下载一个文件时的并发数，默认为4
+func (d *Downloader) SetConcurrency(n int) *Downloader {
+	if n > 0 {
+		d.concurrency = n
+	}
+	return d
+}
+
+// SetOnProgress 设置进度回调，每次收到数据时都会调用，回调不会被并发调用
+func (d *Downloader) SetOnProgress(f func(DownloadProgress)) *Downloader {
+	d.onProgress = f
+	return d
+}
+
+// Download 依次下载 tracks。
+//
+// 一个地址失败时会换用 BackupUrl 中的其它地址。param 是获取这些流时使用的 GetVideoStream 的参数，
+// 全部地址都失败（例如地址过期）后用它重新获取地址。param.Cid 为0时不会重新获取地址。
+func (d *Downloader) Download(param GetVideoStreamParam, tracks ...DownloadTrack) error {
+	for _, track := range tracks {
+		if track.Stream == nil || len(track.Path) == 0 {
+			return errors.Wrap(ErrInvalidParam, "没有指定要下载的流或者保存的路径")
+		}
+		source := &streamSource{urls: streamUrls(track.Stream), refresh: d.refresher(param, track.Stream)}
+		if err := d.downloadTrack(source, track.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadState 是保存在 .part.json 中的下载进度
+type downloadState struct {
+	Total     int64  `json:"total"`
+	ChunkSize int64  `json:"chunk_size"`
+	Done      []bool `json:"done"`
+}
+
+func (d *Downloader) downloadTrack(source *streamSource, path string) error {
+	total, err := d.probe(source)
+	if err != nil {
+		return err
+	}
+	partPath, statePath := path+".part", path+".part.json"
+	state := loadDownloadState(statePath, partPath, total, d.chunkSize)
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err = f.Truncate(total); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var (
+		mu         sync.Mutex
+		downloaded int64
+		firstErr   error
+		wg         sync.WaitGroup
+		chunks     = make(chan int)
+	)
+	for i, done := range state.Done {
+		if done {
+			downloaded += chunkLength(i, state.ChunkSize, total)
+		}
+	}
+	d.report(DownloadProgress{Path: path, Downloaded: downloaded, Total: total})
+	add := func(n int64) {
+		mu.Lock()
+		downloaded += n
+		progress := DownloadProgress{Path: path, Downloaded: downloaded, Total: total}
+		mu.Unlock()
+		d.report(progress)
+	}
+	for i := 0; i < d.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range chunks {
+				err := d.downloadChunk(source, f, int64(index)*state.ChunkSize, chunkLength(index, state.ChunkSize, total), total, add)
+				mu.Lock()
+				if err == nil {
+					state.Done[index] = true
+					err = saveDownloadState(statePath, state)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	ctx := d.client.Context()
+	for index, done := range state.Done {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		if !done {
+			chunks <- index
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = errors.WithStack(ctx.Err())
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if info.Size() != total || downloaded != total {
+		return errors.Errorf("文件大小不正确，应为%d，实际为%d", total, info.Size())
+	}
+	if err = f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.Rename(partPath, path); err != nil {
+		return errors.WithStack(err)
+	}
+	// 空文件没有需要下载的块，不会保存进度
+	if err = os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// probe 请求第一个字节，从 Content-Range 中取得文件的总大小。
+// 文件为空时服务器会返回 416 或者没有 Content-Range 的空响应，此时大小为0
+func (d *Downloader) probe(source *streamSource) (int64, error) {
+	for {
+		url, err := source.url()
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		err = d.fetch(url, 0, 1, func(resp *http.Response) error {
+			_, _, total, err = parseContentRange(resp)
+			return err
+		})
+		if err == nil {
+			return total, nil
+		}
+		if errors.Is(err, errEmptyFile) {
+			return 0, nil
+		}
+		if err = source.fail(url, err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// downloadChunk 下载 [start, start+length) 的内容并写入 f，失败时换用其它地址重试
+func (d *Downloader) downloadChunk(source *streamSource, f io.WriterAt, start, length, total int64, add func(int64)) error {
+	for {
+		url, err := source.url()
+		if err != nil {
+			return err
+		}
+		var written int64
+		err = d.fetch(url, start, length, func(resp *http.Response) error {
+			// 重试时重新写入整个块，撤销上一次计入的进度
+			add(-written)
+			written = 0
+			first, last, size, err := parseContentRange(resp)
+			if err != nil {
+				return err
+			}
+			if size != total || first != start || last-first+1 != length {
+				return errors.Errorf("返回的范围不正确: %s", resp.Header.Get("Content-Range"))
+			}
+			w := &offsetWriter{w: f, offset: start, add: func(n int64) {
+				written += n
+				add(n)
+			}}
+			n, err := io.Copy(w, io.LimitReader(resp.Body, length))
+			if err == nil && n != length {
+				err = io.ErrUnexpectedEOF
+			}
+			return errors.WithStack(err)
+		})
+		if err == nil {
+			return nil
+		}
+		add(-written)
+		if ctxErr := d.client.Context().Err(); ctxErr != nil {
+			return errors.WithStack(ctxErr)
+		}
+		if err = source.fail(url, err); err != nil {
+			return err
+		}
+	}
+}
+
+// fetch 经过中间件、重试策略和限流器发起 Range 请求，把响应交给 handle 处理。重试时 handle 会被再次调用
+func (d *Downloader) fetch(url string, start, length int64, handle func(*http.Response) error) error {
+	return d.client.call(http.MethodGet, url, func(c *Client) error {
+		r := d.resty.R().
+			SetContext(c.Context()).
+			SetDoNotParseResponse(true).
+			SetHeader("Accept", "*/*").
+			SetHeader("Range", fmt.Sprintf("bytes=%d-%d", start, start+length-1))
+		resp, err := r.Get(url)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		body := resp.RawBody()
+		defer func() { _ = body.Close() }()
+		recordResponse(r.Context(), r.QueryParam, resp.StatusCode())
+		contentRange := resp.Header().Get("Content-Range")
+		switch {
+		case resp.StatusCode() == http.StatusPartialContent:
+			return handle(resp.RawResponse)
+		case resp.StatusCode() == http.StatusRequestedRangeNotSatisfiable && (contentRange == "" || contentRange == "bytes */0"),
+			resp.StatusCode() == http.StatusOK && contentRange == "" && resp.RawResponse.ContentLength == 0:
+			return errors.WithStack(errEmptyFile)
+		}
+		return errors.WithStack(StatusError{StatusCode: resp.StatusCode()})
+	})
+}
+
+// errEmptyFile 表示请求的文件为空：响应为 416 且 Content-Range 为空或者为 "bytes */0"，或者是没有 Content-Range 的空响应
+var errEmptyFile = errors.New("文件为空")
+
+func (d *Downloader) report(progress DownloadProgress) {
+	if d.onProgress == nil {
+		return
+	}
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	d.onProgress(progress)
+}
+
+// refresher 返回重新获取 stream 地址的函数，param.Cid 为0时无法重新获取，返回 nil
+func (d *Downloader) refresher(param GetVideoStreamParam, stream *AudioOrVideo) func() ([]string, error) {
+	if param.Cid == 0 {
+		return nil
+	}
+	return func() ([]string, error) {
+		result, err := d.client.GetVideoStream(param)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range dashStreams(&result.Dash) {
+			if s.Id == stream.Id && s.Codecid == stream.Codecid {
+				return streamUrls(s), nil
+			}
+		}
+		return nil, errors.Errorf("重新获取地址后没有找到 id 为%d的流", stream.Id)
+	}
+}
+
+// streamSource 管理一个流的全部地址
+type streamSource struct {
+	mu        sync.Mutex
+	urls      []string
+	index     int
+	refreshes int
+	refresh   func() ([]string, error)
+}
+
+func (s *streamSource) url() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index >= len(s.urls) {
+		return "", errors.New("没有可用的下载地址")
+	}
+	return s.urls[s.index], nil
+}
+
+// fail 记录 url 请求失败并换用下一个地址，全部地址都失败后才重新获取地址。无法继续时返回错误。
+func (s *streamSource) fail(url string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index < len(s.urls) && s.urls[s.index] != url {
+		// 其它 goroutine 已经换过地址了
+		return nil
+	}
+	if s.index+1 < len(s.urls) {
+		s.index++
+		return nil
+	}
+	if s.refresh == nil || s.refreshes >= maxUrlRefreshes {
+		return err
+	}
+	s.refreshes++
+	urls, refreshErr := s.refresh()
+	if refreshErr != nil {
+		return refreshErr
+	}
+	s.urls, s.index = urls, 0
+	return nil
+}
+
+// streamUrls 返回 stream 的全部地址，BaseUrl 在前，BackupUrl 在后
+func streamUrls(stream *AudioOrVideo) []string {
+	var urls []string
+	for _, url := range append([]string{stream.BaseUrl, stream.Baseurl}, append(stream.BackupUrl, stream.Backupurl...)...) {
+		if len(url) > 0 && !contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// dashStreams 返回 dash 中全部的视频流和音频流，包括杜比和无损音轨
+func dashStreams(dash *Dash) []*AudioOrVideo {
+	var streams []*AudioOrVideo
+	for i := range dash.Video {
+		streams = append(streams, &dash.Video[i])
+	}
+	return append(streams, dashAudios(dash)...)
+}
+
+// dashAudios 返回 dash 中全部的音频流，包括杜比和无损音轨
+func dashAudios(dash *Dash) []*AudioOrVideo {
+	var audios []*AudioOrVideo
+	for i := range dash.Audio {
+		audios = append(audios, &dash.Audio[i])
+	}
+	for i := range dash.Dolby.Audio {
+		audios = append(audios, &dash.Dolby.Audio[i])
+	}
+	if dash.Flac.Audio.Id != 0 {
+		audios = append(audios, &dash.Flac.Audio)
+	}
+	return audios
+}
+
+func parseContentRange(resp *http.Response) (first, last, total int64, err error) {
+	m := regContentRange.FindStringSubmatch(resp.Header.Get("Content-Range"))
+	if len(m) == 0 {
+		return 0, 0, 0, errors.Errorf("无法解析 Content-Range: %s", resp.Header.Get("Content-Range"))
+	}
+	first, _ = strconv.ParseInt(m[1], 10, 64)
+	last, _ = strconv.ParseInt(m[2], 10, 64)
+	total, _ = strconv.ParseInt(m[3], 10, 64)
+	return
+}
+
+func chunkLength(index int, chunkSize, total int64) int64 {
+	start := int64(index) * chunkSize
+	if start+chunkSize > total {
+		return total - start
+	}
+	return chunkSize
+}
+
+// loadDownloadState 读取上次下载的进度，文件大小或者分块大小不同时重新开始
+func loadDownloadState(statePath, partPath string, total, chunkSize int64) *downloadState {
+	state := &downloadState{Total: total, ChunkSize: chunkSize, Done: make([]bool, (total+chunkSize-1)/chunkSize)}
+	if _, err := os.Stat(partPath); err != nil {
+		return state
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return state
+	}
+	var saved downloadState
+	if json.Unmarshal(data, &saved) != nil || saved.Total != total || saved.ChunkSize <= 0 ||
+		int64(len(saved.Done)) != (total+saved.ChunkSize-1)/saved.ChunkSize {
+		return state
+	}
+	return &saved
+}
+
+func saveDownloadState(statePath string, state *downloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(statePath, data, 0644))
+}
+
+// offsetWriter 从 offset 开始写入 w
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+	add    func(int64)
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	o.add(int64(n))
+	return n, err
+}
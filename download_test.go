@@ -0,0 +1,235 @@
+package bilibili_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestDownloader(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.HandleRaw("/video.m4s", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://www.bilibili.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "video.m4s", time.Time{}, bytes.NewReader(content))
+	})
+	server.HandleRaw("/broken.m4s", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "video.m4s")
+	stream := &bilibili.AudioOrVideo{Id: 80, BaseUrl: server.URL + "/broken.m4s", BackupUrl: []string{server.URL + "/video.m4s"}}
+	var last bilibili.DownloadProgress
+	d := server.NewClient().NewDownloader().SetChunkSize(4096).SetConcurrency(3).SetOnProgress(func(p bilibili.DownloadProgress) {
+		last = p
+	})
+	if err := d.Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("downloaded content not correct ", err)
+	}
+	if last.Downloaded != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Fatal("progress not correct ", last)
+	}
+	if _, err := os.Stat(path + ".part.json"); !os.IsNotExist(err) {
+		t.Fatal("state file should be removed")
+	}
+
+	// 已经完成的块不会重新下载
+	path = filepath.Join(dir, "resume.m4s")
+	partial := make([]byte, len(content))
+	copy(partial[:8192], content)
+	if err := os.WriteFile(path+".part", partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := json.Marshal(bilibili.DownloadState{Total: int64(len(content)), ChunkSize: 8192, Done: []bool{true, false}})
+	if err := os.WriteFile(path+".part.json", state, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	ranges = nil
+	mu.Unlock()
+	if err := d.Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("resumed content not correct ", err)
+	}
+	if strings.Join(ranges, ",") != "bytes=0-0,bytes=8192-15999" {
+		t.Fatal("only the unfinished chunk should be requested, got ", ranges)
+	}
+
+	// 全部地址都失败，且无法重新获取地址
+	stream = &bilibili.AudioOrVideo{Id: 80, BaseUrl: server.URL + "/broken.m4s"}
+	if err := d.Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: filepath.Join(dir, "broken.m4s")}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDownloaderCall(t *testing.T) {
+	content := []byte("0123456789")
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var count int
+	server.HandleRaw("/video.m4s", func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count == 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		http.ServeContent(w, r, "video.m4s", time.Time{}, bytes.NewReader(content))
+	})
+
+	var calls []bilibili.Call
+	c := server.NewClient().
+		SetRetryPolicy(&bilibili.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}).
+		Use(func(next bilibili.Invoker) bilibili.Invoker {
+			return func(call *bilibili.Call) error {
+				err := next(call)
+				calls = append(calls, *call)
+				return err
+			}
+		})
+	stream := &bilibili.AudioOrVideo{Id: 80, BaseUrl: server.URL + "/video.m4s?deadline=1"}
+	path := filepath.Join(t.TempDir(), "video.m4s")
+	err := c.NewDownloader().SetConcurrency(1).Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("downloaded content not correct ", err)
+	}
+	// 探测大小的请求和下载的请求都经过中间件，失败的请求按照重试策略重试
+	if len(calls) != 2 || calls[0].Endpoint != "/video.m4s" || calls[1].Attempts != 2 || calls[1].StatusCode != http.StatusPartialContent {
+		t.Fatal("calls not correct ", calls)
+	}
+}
+
+func TestDownloaderRedirect(t *testing.T) {
+	content := []byte("0123456789")
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.HandleRaw("/upos.m4s", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/mirror.m4s", http.StatusFound)
+	})
+	server.HandleRaw("/mirror.m4s", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://www.bilibili.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "mirror.m4s", time.Time{}, bytes.NewReader(content))
+	})
+	server.HandleRaw("/empty.m4s", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "empty.m4s", time.Time{}, bytes.NewReader(nil))
+	})
+	server.HandleRaw("/unsatisfiable.m4s", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes */0")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	})
+
+	dir := t.TempDir()
+	d := server.NewClient().NewDownloader()
+	path := filepath.Join(dir, "video.m4s")
+	stream := &bilibili.AudioOrVideo{Id: 80, BaseUrl: server.URL + "/upos.m4s"}
+	if err := d.Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("redirected content not correct ", err)
+	}
+
+	// 文件为空时，http.ServeContent 返回没有 Content-Range 的 200，CDN 可能返回 416
+	for _, name := range []string{"empty.m4s", "unsatisfiable.m4s"} {
+		path = filepath.Join(dir, name)
+		stream = &bilibili.AudioOrVideo{Id: 80, BaseUrl: server.URL + "/" + name}
+		if err := d.Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+			t.Fatal(name, err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+			t.Fatal("empty file should be downloaded ", name, err)
+		}
+	}
+}
+
+func TestDownloaderRefreshUrl(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	content := bytes.Repeat([]byte("m4s"), 1000)
+	server.HandleRaw("/old.m4s", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden) // 地址已过期
+	})
+	server.HandleRaw("/new.m4s", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "audio.m4s", time.Time{}, bytes.NewReader(content))
+	})
+	server.HandleFixture("/x/player/wbi/playurl", map[string]any{
+		"dash": map[string]any{"audio": []map[string]any{{"id": 30280, "base_url": server.URL + "/new.m4s"}}},
+	})
+
+	param := bilibili.GetVideoStreamParam{Bvid: "BV17x411w7KC", Cid: 279786, Fnval: 16}
+	stream := &bilibili.AudioOrVideo{Id: 30280, BaseUrl: server.URL + "/old.m4s"}
+	path := filepath.Join(t.TempDir(), "audio.m4s")
+	if err := client.NewDownloader().Download(param, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("downloaded content not correct ", err)
+	}
+	if count := server.RequestCount("/x/player/wbi/playurl"); count != 1 {
+		t.Fatal("expected 1 playurl request, got ", count)
+	}
+}
+
+func TestDownloaderBackupUrl(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	content := bytes.Repeat([]byte("m4s"), 1000)
+	server.HandleRaw("/base.m4s", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	server.HandleRaw("/backup.m4s", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "audio.m4s", time.Time{}, bytes.NewReader(content))
+	})
+
+	// 没有 cid 无法重新获取地址，只能使用 BackupUrl
+	stream := &bilibili.AudioOrVideo{Id: 30280, BaseUrl: server.URL + "/base.m4s", BackupUrl: []string{server.URL + "/backup.m4s"}}
+	path := filepath.Join(t.TempDir(), "audio.m4s")
+	if err := client.NewDownloader().Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+		t.Fatal("downloaded content not correct ", err)
+	}
+
+	stream.BackupUrl = nil
+	err := client.NewDownloader().Download(bilibili.GetVideoStreamParam{}, bilibili.DownloadTrack{Stream: stream, Path: path + "2"})
+	if !errors.As(err, &bilibili.StatusError{}) {
+		t.Fatal("expected StatusError when all urls fail, got ", err)
+	}
+}
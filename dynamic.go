@@ -1,12 +1,10 @@
 package bilibili
 
 import (
-	"encoding/json"
 	"io"
 
 	"github.com/bitly/go-simplejson"
 	"github.com/go-resty/resty/v2"
-	"github.com/pkg/errors"
 )
 
 type SearchDynamicAtParam struct {
@@ -213,9 +211,9 @@ func (c *Client) GetDynamicRepostDetail(param GetDynamicRepostDetailParam) (*Dyn
 }
 
 type GetDynamicLikeListParam struct {
-	DynamicId int64 `json:"dynamic_id"`                             // 动态id
-	Pn        int64 `json:"pn,omitempty" request:"query,omitempty"` // 页码
-	Ps        int64 `json:"ps,omitempty" request:"query,omitempty"` // 每页数量。该值不得大于20
+	DynamicId int64 `json:"dynamic_id"`                                    // 动态id
+	Pn        int64 `json:"pn,omitempty" request:"query,omitempty"`        // 页码
+	Ps        int64 `json:"ps,omitempty" request:"query,omitempty,max=20"` // 每页数量。该值不得大于20
 }
 
 type DynamicLikeList struct {
@@ -479,36 +477,39 @@ func (c *Client) GetDynamicPortal() (*DynamicPortal, error) {
 	return execute[*DynamicPortal](c, method, url, nil)
 }
 
+type uploadDynamicBfsParam struct {
+	File     io.Reader `json:"file_up" request:"form-data"`
+	Category string    `json:"category"`
+}
+
+type uploadDynamicBfsResult struct {
+	ImageUrl    string `json:"image_url"`
+	ImageWidth  int    `json:"image_width"`
+	ImageHeight int    `json:"image_height"`
+}
+
+// namedReader 为上传的文件指定文件名
+type namedReader struct {
+	io.Reader
+	name string
+}
+
+func (r namedReader) Name() string {
+	return r.name
+}
+
 // UploadDynamicBfs 为图片动态上传图片
 func (c *Client) UploadDynamicBfs(fileName string, file io.Reader, category string) (url string, size Size, err error) {
-	biliJct := c.getCookie("bili_jct")
-	if len(biliJct) == 0 {
-		return "", Size{}, errors.New("B站登录过期")
-	}
-	resp, err := c.resty.R().
-		SetFileReader("file_up", fileName, file).SetQueryParams(map[string]string{
-		"category": category,
-		"csrf":     biliJct,
-	}).Post("https://api.bilibili.com/x/dynamic/feed/draw/upload_bfs")
+	const (
+		method    = resty.MethodPost
+		uploadUrl = "https://api.bilibili.com/x/dynamic/feed/draw/upload_bfs"
+	)
+	param := uploadDynamicBfsParam{File: namedReader{Reader: file, name: fileName}, Category: category}
+	data, err := execute[*uploadDynamicBfsResult](c, method, uploadUrl, param, fillCsrf(c))
 	if err != nil {
-		return "", Size{}, errors.WithStack(err)
-	}
-	if resp.StatusCode() != 200 {
-		return "", Size{}, errors.Errorf("status code: %d", resp.StatusCode())
-	}
-	var response commonResp[struct {
-		ImageUrl    string `json:"image_url"`
-		ImageWidth  int    `json:"image_width"`
-		ImageHeight int    `json:"image_height"`
-	}]
-	if err = json.Unmarshal(resp.Body(), &response); err != nil {
-		return "", Size{}, errors.WithStack(err)
-	}
-	if response.Code != 0 {
-		return "", Size{}, errors.Errorf("错误码: %d, 错误信息: %s", response.Code, response.Message)
+		return "", Size{}, err
 	}
-	data := response.Data
-	return data.ImageUrl, Size{Width: data.ImageWidth, Height: data.ImageHeight}, errors.WithStack(err)
+	return data.ImageUrl, Size{Width: data.ImageWidth, Height: data.ImageHeight}, nil
 }
 
 type CreateDynamicParam struct {
@@ -0,0 +1,91 @@
+package bilibili
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Error 是B站返回的错误码和错误信息
+//
+// 可以使用 errors.Is 判断是否是某个错误码，只比较 Code，不比较 Message，例如：
+//
+//	if errors.Is(err, bilibili.ErrNotLoggedIn) { ... }
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("错误码: %d, 错误信息: %s", e.Code, e.Message)
+}
+
+// Is 支持 errors.Is，错误码相同即认为相同
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Code == e.Code
+}
+
+// StatusError 表示B站返回了非200的HTTP状态码
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("status code: %d", e.StatusCode)
+}
+
+// 常见的B站错误码，配合 errors.Is 使用
+var (
+	ErrNotLoggedIn    = Error{Code: -101, Message: "账号未登录"}
+	ErrCoinNotEnough  = Error{Code: -104, Message: "硬币不足"}
+	ErrCsrfFailed     = Error{Code: -111, Message: "csrf 校验失败"}
+	ErrRiskControl    = Error{Code: -352, Message: "风控校验失败"}
+	ErrNoPermission   = Error{Code: -403, Message: "访问权限不足"}
+	ErrNotFound       = Error{Code: -404, Message: "啥都木有"}
+	ErrRequestBlocked = Error{Code: -412, Message: "请求被拦截"}
+	ErrRateLimited    = Error{Code: -799, Message: "请求过于频繁，请稍后再试"}
+	ErrCoinLimit      = Error{Code: 34005, Message: "超过投币上限"}
+)
+
+var (
+	ErrInvalidParam     = errors.New("参数类型错误")                      // 传入的参数类型不正确
+	ErrInvalidPublicKey = errors.New("failed to decode public key") // 登录时B站返回的公钥无法解析
+	ErrNoRefreshToken   = errors.New("没有refresh_token，无法刷新cookies") // 刷新cookies需要登录时返回的refresh_token
+)
+
+// IsNotLoggedIn 判断是否是未登录或登录已过期的错误
+func IsNotLoggedIn(err error) bool {
+	return errors.Is(err, ErrNotLoggedIn)
+}
+
+// IsRateLimited 判断是否是请求过于频繁的错误，包括错误码 -799 和 HTTP 429
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited) || isStatus(err, http.StatusTooManyRequests)
+}
+
+// IsRiskControl 判断是否是被风控拦截的错误，包括错误码 -352、-412 和 HTTP 412
+func IsRiskControl(err error) bool {
+	return errors.Is(err, ErrRiskControl) || errors.Is(err, ErrRequestBlocked) || isStatus(err, http.StatusPreconditionFailed)
+}
+
+// IsNotFound 判断是否是资源不存在的错误
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsCsrfFailed 判断是否是 csrf 校验失败的错误
+func IsCsrfFailed(err error) bool {
+	return errors.Is(err, ErrCsrfFailed)
+}
+
+// IsCoinLimit 判断是否是超过投币上限的错误
+func IsCoinLimit(err error) bool {
+	return errors.Is(err, ErrCoinLimit)
+}
+
+func isStatus(err error, statusCode int) bool {
+	var se StatusError
+	return errors.As(err, &se) && se.StatusCode == statusCode
+}
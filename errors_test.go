@@ -0,0 +1,31 @@
+package bilibili
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := errors.WithStack(Error{Code: -101, Message: "B站登录过期"})
+	if !errors.Is(err, ErrNotLoggedIn) || !IsNotLoggedIn(err) {
+		t.Fatal("errors.Is should only compare Code")
+	}
+	if errors.Is(err, ErrNotFound) || IsNotFound(err) || IsRateLimited(err) {
+		t.Fatal("errors.Is should not match a different Code")
+	}
+
+	if !IsRiskControl(errors.WithStack(StatusError{StatusCode: 412})) || !IsRiskControl(errors.Wrap(ErrRequestBlocked, "test")) {
+		t.Fatal("IsRiskControl not correct")
+	}
+	if !IsRateLimited(errors.WithStack(StatusError{StatusCode: 429})) || !IsRateLimited(ErrRateLimited) {
+		t.Fatal("IsRateLimited not correct")
+	}
+	if !IsCoinLimit(Error{Code: 34005}) || !IsCsrfFailed(Error{Code: -111}) {
+		t.Fatal("predicates not correct")
+	}
+
+	if err = fillCsrf(New())(New().resty.R()); !IsNotLoggedIn(err) {
+		t.Fatal("fillCsrf should return ErrNotLoggedIn, got ", err)
+	}
+}
@@ -0,0 +1,49 @@
+package bilibili
+
+import "time"
+
+// 导出部分内部实现，供 bilibili_test 包中的测试使用
+
+const (
+	WbiCacheKey        = cacheKeys
+	MaxWbiInitAttempts = maxInitAttempts
+)
+
+type DownloadState = downloadState
+
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	return p.delay(attempt)
+}
+
+func (l *RateLimiter) SetNow(now func() time.Time) {
+	l.now = now
+}
+
+func (l *RateLimiter) Reserve(rawUrl string) (time.Duration, error) {
+	_, wait, err := l.reserve(rawUrl)
+	return wait, err
+}
+
+func (m *MemoryCache) SetNow(now func() time.Time) {
+	m.now = now
+}
+
+func (p *AccountPool) SetNow(now func() time.Time) {
+	p.now = now
+}
+
+func (p *AccountPool) SetStrategy(strategy PoolStrategy) {
+	p.strategy = strategy
+}
+
+func (p *ProxyPool) SetNow(now func() time.Time) {
+	p.now = now
+}
+
+func (p *ProxyPool) SetStrategy(strategy ProxyStrategy) {
+	p.strategy = strategy
+}
+
+func (c *Client) Middlewares() int {
+	return len(c.middlewares)
+}
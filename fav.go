@@ -229,6 +229,37 @@ type GetFavourListParam struct {
 	Platform string `json:"platform,omitempty" request:"query,omitempty"` // 平台标识。可为web（影响内容列表类型）
 }
 
+// FavourMedia 收藏夹中的一项内容
+type FavourMedia struct {
+	Id       int      `json:"id"`       // 内容id，视频稿件：视频稿件avid，音频：音频auid，视频合集：视频合集id
+	Type     int      `json:"type"`     // 内容类型，2：视频稿件，12：音频，21：视频合集
+	Title    string   `json:"title"`    // 标题
+	Cover    string   `json:"cover"`    // 封面url
+	Intro    string   `json:"intro"`    // 简介
+	Page     int      `json:"page"`     // 视频分P数
+	Duration int      `json:"duration"` // 音频/视频时长
+	Upper    struct { // UP主信息
+		Mid  int    `json:"mid"`  // UP主mid
+		Name string `json:"name"` // UP主昵称
+		Face string `json:"face"` // UP主头像url
+	} `json:"upper"`
+	Attr    int      `json:"attr"` // 属性位（？）
+	CntInfo struct { // 状态数
+		Collect int `json:"collect"` // 收藏数
+		Play    int `json:"play"`    // 播放数
+		Danmaku int `json:"danmaku"` // 弹幕数
+	} `json:"cnt_info"`
+	Link    string `json:"link"`     // 跳转uri
+	Ctime   int    `json:"ctime"`    // 投稿时间戳
+	Pubtime int    `json:"pubtime"`  // 发布时间戳
+	FavTime int    `json:"fav_time"` // 收藏时间戳
+	BvId    string `json:"bv_id"`    // 视频稿件bvid
+	Bvid    string `json:"bvid"`     // 视频稿件bvid
+	Ugc     struct {
+		FirstCid int `json:"first_cid"` // 视频cid
+	} `json:"ugc"`
+}
+
 type FavourList struct {
 	Info struct { // 收藏夹元数据
 		Id    int      `json:"id"`    // 收藏夹mlid（完整id），收藏夹原始id+创建者mid尾号2位
@@ -261,36 +292,8 @@ type FavourList struct {
 		LikeState  int    `json:"like_state"`  // 点赞状态，已点赞：1，未点赞：0
 		MediaCount int    `json:"media_count"` // 收藏夹内容数量
 	} `json:"info"`
-	Medias []struct { // 收藏夹内容
-		Id       int      `json:"id"`       // 内容id，视频稿件：视频稿件avid，音频：音频auid，视频合集：视频合集id
-		Type     int      `json:"type"`     // 内容类型，2：视频稿件，12：音频，21：视频合集
-		Title    string   `json:"title"`    // 标题
-		Cover    string   `json:"cover"`    // 封面url
-		Intro    string   `json:"intro"`    // 简介
-		Page     int      `json:"page"`     // 视频分P数
-		Duration int      `json:"duration"` // 音频/视频时长
-		Upper    struct { // UP主信息
-			Mid  int    `json:"mid"`  // UP主mid
-			Name string `json:"name"` // UP主昵称
-			Face string `json:"face"` // UP主头像url
-		} `json:"upper"`
-		Attr    int      `json:"attr"` // 属性位（？）
-		CntInfo struct { // 状态数
-			Collect int `json:"collect"` // 收藏数
-			Play    int `json:"play"`    // 播放数
-			Danmaku int `json:"danmaku"` // 弹幕数
-		} `json:"cnt_info"`
-		Link    string `json:"link"`     // 跳转uri
-		Ctime   int    `json:"ctime"`    // 投稿时间戳
-		Pubtime int    `json:"pubtime"`  // 发布时间戳
-		FavTime int    `json:"fav_time"` // 收藏时间戳
-		BvId    string `json:"bv_id"`    // 视频稿件bvid
-		Bvid    string `json:"bvid"`     // 视频稿件bvid
-		Ugc     struct {
-			FirstCid int `json:"first_cid"` // 视频cid
-		} `json:"ugc"`
-	} `json:"medias"`
-	HasMore bool `json:"has_more"`
+	Medias  []FavourMedia `json:"medias"` // 收藏夹内容
+	HasMore bool          `json:"has_more"`
 }
 
 // GetFavourList 获取收藏夹内容明细列表
@@ -5,11 +5,11 @@ import (
 )
 
 type GetHistoryParam struct {
-	Max      int    `json:"max,omitempty" request:"query,omitempty"`      // 历史记录截止目标 id。默认为 0。稿件：稿件 avid。剧集（番剧 / 影视）：剧集 ssid。直播：直播间 id。文集：文集 rlid。文章：文章 cvid
-	Business string `json:"business,omitempty" request:"query,omitempty"` // 历史记录截止目标业务类型。默认为空。archive：稿件。pgc：剧集（番剧 / 影视）。live：直播。article-list：文集。article：文章
-	ViewAt   int    `json:"view_at,omitempty" request:"query,omitempty"`  // 历史记录截止时间。时间戳。默认为 0。0 为当前 时间
-	Type     string `json:"type,omitempty" request:"query,omitempty"`     // 历史记录分类筛选。all：全部类型（默认）。archive：稿件。live：直播。article：文章
-	Ps       int    `json:"ps,omitempty" request:"query,omitempty"`       // 每页项数。默认为 20，最大 30
+	Max      int    `json:"max,omitempty" request:"query,omitempty"`       // 历史记录截止目标 id。默认为 0。稿件：稿件 avid。剧集（番剧 / 影视）：剧集 ssid。直播：直播间 id。文集：文集 rlid。文章：文章 cvid
+	Business string `json:"business,omitempty" request:"query,omitempty"`  // 历史记录截止目标业务类型。默认为空。archive：稿件。pgc：剧集（番剧 / 影视）。live：直播。article-list：文集。article：文章
+	ViewAt   int    `json:"view_at,omitempty" request:"query,omitempty"`   // 历史记录截止时间。时间戳。默认为 0。0 为当前 时间
+	Type     string `json:"type,omitempty" request:"query,omitempty"`      // 历史记录分类筛选。all：全部类型（默认）。archive：稿件。live：直播。article：文章
+	Ps       int    `json:"ps,omitempty" request:"query,omitempty,max=30"` // 每页项数。默认为 20，最大 30
 }
 
 type HistoryCursor struct {
@@ -0,0 +1,100 @@
+package bilibili
+
+import (
+	"strings"
+	"sync"
+)
+
+// Host 是B站接口所在的域名，可以通过 Client.SetHost 把它重定向到其它地址
+type Host string
+
+const (
+	HostApi      Host = "https://api.bilibili.com"      // 主站接口
+	HostApiVc    Host = "https://api.vc.bilibili.com"   // 动态、私信等接口
+	HostPassport Host = "https://passport.bilibili.com" // 登录相关接口
+	HostLive     Host = "https://api.live.bilibili.com" // 直播相关接口
+	HostWww      Host = "https://www.bilibili.com"      // 主站网页，刷新cookies时需要
+)
+
+// AllHosts 是本库主要使用的全部 Host
+var AllHosts = []Host{HostApi, HostApiVc, HostPassport, HostLive, HostWww}
+
+// hostRegistry 记录 Host 到替换地址的映射，可以被多个 Client 浅拷贝共享
+type hostRegistry struct {
+	mu    sync.RWMutex
+	hosts map[Host]string
+}
+
+func (h *hostRegistry) set(host Host, baseUrl string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	host = Host(strings.TrimSuffix(string(host), "/"))
+	if len(baseUrl) == 0 {
+		delete(h.hosts, host)
+		return
+	}
+	if h.hosts == nil {
+		h.hosts = make(map[Host]string, len(AllHosts))
+	}
+	h.hosts[host] = strings.TrimSuffix(baseUrl, "/")
+}
+
+func (h *hostRegistry) get(host Host) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if baseUrl, ok := h.hosts[host]; ok {
+		return baseUrl
+	}
+	return string(host)
+}
+
+// rewrite 将 url 的 scheme 和 host 部分替换为设置的地址，未设置时原样返回
+func (h *hostRegistry) rewrite(url string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for host, baseUrl := range h.hosts {
+		rest := strings.TrimPrefix(url, string(host))
+		if len(rest) == len(url) {
+			continue
+		}
+		if len(rest) == 0 || rest[0] == '/' || rest[0] == '?' {
+			return baseUrl + rest
+		}
+	}
+	return url
+}
+
+// SetHost 将 host 下的所有接口重定向到 baseUrl，例如：
+//
+//	client.SetHost(bilibili.HostApi, "http://127.0.0.1:8080")
+//
+// 之后 https://api.bilibili.com/x/web-interface/view 会被请求为 http://127.0.0.1:8080/x/web-interface/view 。
+// baseUrl 可以带有路径前缀，例如 "https://gateway.example.com/bilibili-api"。baseUrl 为空时恢复默认。
+//
+// host 不局限于 AllHosts 中列出的值，任何形如 "https://xxx.bilibili.com" 的地址都可以设置。
+// 包括获取 WBI 签名密钥在内的所有请求都会遵循该设置。
+func (c *Client) SetHost(host Host, baseUrl string) *Client {
+	c.hosts.set(host, baseUrl)
+	return c
+}
+
+// SetAllHosts 将 AllHosts 中的所有 Host 都重定向到同一个 baseUrl，适合测试时指向一个 httptest.Server
+func (c *Client) SetAllHosts(baseUrl string) *Client {
+	for _, host := range AllHosts {
+		c.hosts.set(host, baseUrl)
+	}
+	return c
+}
+
+// GetHost 获取 host 当前实际使用的地址
+func (c *Client) GetHost(host Host) string {
+	return c.hosts.get(Host(strings.TrimSuffix(string(host), "/")))
+}
+
+// resolveUrl 根据 SetHost 的设置改写 url
+func (c *Client) resolveUrl(url string) string {
+	return c.hosts.rewrite(url)
+}
@@ -0,0 +1,69 @@
+package bilibili
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRewrite(t *testing.T) {
+	c := New()
+	c.SetHost(HostApi, "http://127.0.0.1:8080/")
+	c.SetHost(HostLive, "https://gateway.example.com/live")
+
+	cases := map[string]string{
+		"https://api.bilibili.com/x/web-interface/view":      "http://127.0.0.1:8080/x/web-interface/view",
+		"https://api.bilibili.com?a=1":                       "http://127.0.0.1:8080?a=1",
+		"https://api.bilibili.com":                           "http://127.0.0.1:8080",
+		"https://api.bilibili.com.cn/x":                      "https://api.bilibili.com.cn/x",
+		"https://api.vc.bilibili.com/x":                      "https://api.vc.bilibili.com/x",
+		"https://api.live.bilibili.com/room/v1/Area/getList": "https://gateway.example.com/live/room/v1/Area/getList",
+	}
+	for in, expected := range cases {
+		if actual := c.resolveUrl(in); actual != expected {
+			t.Fatalf("resolveUrl(%q) = %q, expected %q", in, actual, expected)
+		}
+	}
+
+	if c.GetHost(HostApi) != "http://127.0.0.1:8080" || c.GetHost(HostApiVc) != string(HostApiVc) {
+		t.Fatal("GetHost result not correct")
+	}
+
+	c.SetHost(HostApi, "")
+	if c.GetHost(HostApi) != string(HostApi) {
+		t.Fatal("SetHost with empty baseUrl should restore default")
+	}
+}
+
+func TestSetAllHosts(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/x/web-interface/nav":
+			_, _ = w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"wbi_img":{` +
+				`"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",` +
+				`"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":0,"message":"0","data":{"addr":"127.0.0.1"}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := New().SetAllHosts(server.URL)
+	zone, err := c.GetZoneLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Addr != "127.0.0.1" {
+		t.Fatal("GetZoneLocation result not correct ", zone)
+	}
+
+	if _, err = c.GetUserVideos(GetUserVideosParam{Mid: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || paths[1] != "/x/web-interface/nav" || paths[2] != "/x/space/wbi/arc/search" {
+		t.Fatal("requests not sent to the test server ", paths)
+	}
+}
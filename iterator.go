@@ -0,0 +1,223 @@
+package bilibili
+
+// PageFunc 获取下一页数据，返回本页的数据以及是否还有下一页。翻页所需的游标、页码等状态由 PageFunc 自己保存。
+type PageFunc[T any] func() (items []T, hasMore bool, err error)
+
+// Pager 按页遍历分页接口
+//
+//	pager := bilibili.NewPager(fetch)
+//	for pager.HasMore() {
+//		items, err := pager.NextPage()
+//		...
+//	}
+type Pager[T any] struct {
+	fetch   PageFunc[T]
+	hasMore bool
+	pages   int
+}
+
+// NewPager 使用 fetch 创建一个 Pager
+func NewPager[T any](fetch PageFunc[T]) *Pager[T] {
+	return &Pager[T]{fetch: fetch, hasMore: true}
+}
+
+// HasMore 返回是否还有下一页。出错后也会返回 false
+func (p *Pager[T]) HasMore() bool {
+	return p.hasMore
+}
+
+// Pages 返回已经获取的页数
+func (p *Pager[T]) Pages() int {
+	return p.pages
+}
+
+// NextPage 获取下一页数据。没有下一页时返回 nil, nil
+func (p *Pager[T]) NextPage() ([]T, error) {
+	if !p.hasMore {
+		return nil, nil
+	}
+	items, hasMore, err := p.fetch()
+	if err != nil {
+		p.hasMore = false
+		return nil, err
+	}
+	p.pages++
+	// 返回空页时也视为没有更多数据，防止接口异常时死循环
+	p.hasMore = hasMore && len(items) > 0
+	return items, nil
+}
+
+// Iterator 逐项遍历分页接口，隐藏翻页的细节
+//
+//	it := client.IterFavourMedias(bilibili.GetFavourListParam{MediaId: mediaId, Ps: 20}).Limit(100)
+//	for it.Next() {
+//		media := it.Value()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	pager *Pager[T]
+	buf   []T
+	cur   T
+	err   error
+	limit int
+	count int
+	done  bool
+}
+
+// NewIterator 使用 fetch 创建一个 Iterator
+func NewIterator[T any](fetch PageFunc[T]) *Iterator[T] {
+	return &Iterator[T]{pager: NewPager(fetch)}
+}
+
+// Limit 限制最多遍历 n 项，n 小于等于0表示不限制
+func (it *Iterator[T]) Limit(n int) *Iterator[T] {
+	it.limit = n
+	return it
+}
+
+// Next 前进到下一项，没有更多数据、出错或者已经达到 Limit 时返回 false
+func (it *Iterator[T]) Next() bool {
+	if it.done || (it.limit > 0 && it.count >= it.limit) {
+		it.done = true
+		return false
+	}
+	for len(it.buf) == 0 {
+		if !it.pager.HasMore() {
+			it.done = true
+			return false
+		}
+		it.buf, it.err = it.pager.NextPage()
+		if it.err != nil {
+			it.done = true
+			return false
+		}
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	it.count++
+	return true
+}
+
+// Value 返回当前项，需要在 Next 返回 true 之后调用
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err 返回遍历过程中遇到的错误
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Stop 提前结束遍历，之后 Next 总是返回 false，不会再发起请求
+func (it *Iterator[T]) Stop() {
+	it.done = true
+	it.buf = nil
+}
+
+// Count 返回已经遍历的项数
+func (it *Iterator[T]) Count() int {
+	return it.count
+}
+
+// All 遍历剩余的所有项并返回。出错时返回已经获取的部分和错误
+func (it *Iterator[T]) All() ([]T, error) {
+	var all []T
+	for it.Next() {
+		all = append(all, it.Value())
+	}
+	return all, it.Err()
+}
+
+// pageNumber 返回 pn 的初始值，pn 为0时从第1页开始
+func pageNumber(pn int) int {
+	if pn <= 0 {
+		return 1
+	}
+	return pn
+}
+
+// IterHistory 遍历历史记录，从 param 指定的位置开始
+func (c *Client) IterHistory(param GetHistoryParam) *Iterator[HistoryList] {
+	return NewIterator(func() ([]HistoryList, bool, error) {
+		result, err := c.GetHistory(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Max, param.ViewAt, param.Business = result.Cursor.Max, result.Cursor.ViewAt, result.Cursor.Business
+		return result.List, result.Cursor.Max != 0 || result.Cursor.ViewAt != 0, nil
+	})
+}
+
+// IterSpaceDynamics 遍历用户空间动态，从 param.Offset 开始
+func (c *Client) IterSpaceDynamics(param GetUserSpaceDynamicParam) *Iterator[DynamicItem] {
+	return NewIterator(func() ([]DynamicItem, bool, error) {
+		result, err := c.GetUserSpaceDynamic(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Offset = result.Offset
+		return result.Items, result.HasMore && len(result.Offset) > 0, nil
+	})
+}
+
+// IterFavourMedias 遍历收藏夹内容，从 param.Pn 页开始
+func (c *Client) IterFavourMedias(param GetFavourListParam) *Iterator[FavourMedia] {
+	param.Pn = pageNumber(param.Pn)
+	return NewIterator(func() ([]FavourMedia, bool, error) {
+		result, err := c.GetFavourList(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Pn++
+		return result.Medias, result.HasMore, nil
+	})
+}
+
+// IterFollowers 遍历用户粉丝，从 param.Pn 页开始。B站仅允许查看前1000名粉丝
+func (c *Client) IterFollowers(param GetUserFollowersParam) *Iterator[RelationUser] {
+	param.Pn = pageNumber(param.Pn)
+	ps := param.Ps
+	if ps <= 0 {
+		ps = 50
+	}
+	fetched := (param.Pn - 1) * ps // 跳过的页也计入已获取的数量，和 Total 比较
+	return NewIterator(func() ([]RelationUser, bool, error) {
+		result, err := c.GetUserFollowers(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Pn++
+		fetched += len(result.List)
+		return result.List, fetched < result.Total, nil
+	})
+}
+
+// IterComments 遍历评论区的根评论，从 param.Pn 页开始
+func (c *Client) IterComments(param GetCommentsDetailParam) *Iterator[*Comment] {
+	param.Pn = pageNumber(param.Pn)
+	return NewIterator(func() ([]*Comment, bool, error) {
+		result, err := c.GetCommentsDetail(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Pn++
+		page := result.Page
+		return result.Replies, page.Num*page.Size < page.Count, nil
+	})
+}
+
+// IterUserVideos 遍历用户投稿视频，从 param.Pn 页开始
+func (c *Client) IterUserVideos(param GetUserVideosParam) *Iterator[UserVideo] {
+	param.Pn = pageNumber(param.Pn)
+	return NewIterator(func() ([]UserVideo, bool, error) {
+		result, err := c.GetUserVideos(param)
+		if err != nil {
+			return nil, false, err
+		}
+		param.Pn++
+		page := result.Page
+		return result.List.Vlist, page.Pn*page.Ps < page.Count, nil
+	})
+}
@@ -0,0 +1,89 @@
+package bilibili_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestIterator(t *testing.T) {
+	pages := [][]int{{1, 2}, {3}, {4, 5}}
+	fetched := 0
+	newIt := func() *bilibili.Iterator[int] {
+		fetched = 0
+		return bilibili.NewIterator(func() ([]int, bool, error) {
+			items := pages[fetched]
+			fetched++
+			return items, fetched < len(pages), nil
+		})
+	}
+
+	all, err := newIt().All()
+	if err != nil || fmt.Sprint(all) != "[1 2 3 4 5]" || fetched != 3 {
+		t.Fatal("All result not correct ", all, err, fetched)
+	}
+
+	all, _ = newIt().Limit(3).All()
+	if fmt.Sprint(all) != "[1 2 3]" || fetched != 2 {
+		t.Fatal("Limit result not correct ", all, fetched)
+	}
+
+	it := newIt()
+	if !it.Next() || it.Value() != 1 {
+		t.Fatal("Next result not correct")
+	}
+	it.Stop()
+	if it.Next() || fetched != 1 {
+		t.Fatal("Stop should end the iteration")
+	}
+
+	it = bilibili.NewIterator(func() ([]int, bool, error) {
+		fetched++
+		if fetched > 1 {
+			return nil, false, bilibili.ErrNotFound
+		}
+		return []int{1}, true, nil
+	})
+	fetched = 0
+	all, err = it.All()
+	if len(all) != 1 || !bilibili.IsNotFound(err) {
+		t.Fatal("error should stop the iteration ", all, err)
+	}
+}
+
+func TestIterFavourMedias(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var mu sync.Mutex
+	var pns []string
+	server.Handle("/x/v3/fav/resource/list", func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		pn := r.URL.Query().Get("pn")
+		mu.Lock()
+		pns = append(pns, pn)
+		mu.Unlock()
+		id, _ := strconv.Atoi(pn)
+		list := bilibili.FavourList{HasMore: pn != "3"}
+		list.Medias = []bilibili.FavourMedia{{Id: id*10 + 1}, {Id: id*10 + 2}}
+		return list, nil
+	})
+
+	c := server.NewClient()
+	medias, err := c.IterFavourMedias(bilibili.GetFavourListParam{MediaId: 1, Ps: 2}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(medias) != 6 || medias[5].Id != 32 || fmt.Sprint(pns) != "[1 2 3]" {
+		t.Fatal("IterFavourMedias result not correct ", medias, pns)
+	}
+
+	pns = nil
+	medias, err = c.IterFavourMedias(bilibili.GetFavourListParam{MediaId: 1, Ps: 2, Pn: 2}).Limit(1).All()
+	if err != nil || len(medias) != 1 || medias[0].Id != 21 || fmt.Sprint(pns) != "[2]" {
+		t.Fatal("IterFavourMedias with limit not correct ", medias, pns)
+	}
+}
@@ -35,17 +35,10 @@ func (c *Client) Captcha() (*CaptchaResult, error) {
 }
 
 func encrypt(publicKey, data string) (string, error) {
-	// pem解码
-	block, _ := pem.Decode([]byte(publicKey))
-	if block == nil {
-		return "", errors.New("failed to decode public key")
-	}
-	// x509解码
-	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pk, err := parsePublicKey(publicKey)
 	if err != nil {
-		return "", errors.WithStack(err)
+		return "", err
 	}
-	pk := publicKeyInterface.(*rsa.PublicKey)
 	// 加密
 	cipherText, err := rsa.EncryptPKCS1v15(rand.Reader, pk, []byte(data))
 	if err != nil {
@@ -55,6 +48,25 @@ func encrypt(publicKey, data string) (string, error) {
 	return base64.URLEncoding.EncodeToString(cipherText), nil
 }
 
+// parsePublicKey 解析PEM格式的RSA公钥
+func parsePublicKey(publicKey string) (*rsa.PublicKey, error) {
+	// pem解码
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, errors.WithStack(ErrInvalidPublicKey)
+	}
+	// x509解码
+	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pk, ok := publicKeyInterface.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.WithStack(ErrInvalidPublicKey)
+	}
+	return pk, nil
+}
+
 type LoginWithPasswordParam struct {
 	Username  string `json:"username"`                                   // 用户登录账号。手机号或邮箱地址
 	Password  string `json:"password"`                                   // 参数传入原密码，下文会自动转为加密后的带盐密码
@@ -104,7 +116,15 @@ func (c *Client) LoginWithPassword(param LoginWithPasswordParam) (*LoginWithPass
 		method2 = resty.MethodPost
 		url2    = "https://passport.bilibili.com/x/passport-login/web/login"
 	)
-	return execute[*LoginWithPasswordResult](c, method2, url2, param)
+	result, err := execute[*LoginWithPasswordResult](c, method2, url2, param)
+	if err != nil {
+		return nil, err
+	}
+	// status 不为0时还需要进行验证，并没有登录成功
+	if result != nil && result.Status == 0 {
+		err = c.loginSucceeded(result.RefreshToken)
+	}
+	return result, err
 }
 
 type CountryCrown struct {
@@ -161,9 +181,10 @@ type LoginWithSMSParam struct {
 }
 
 type LoginWithSMSResult struct {
-	IsNew  bool   `json:"is_new"` // 是否为新注册用户。false：非新注册用户。true：新注册用户
-	Status int    `json:"status"` // 0。未知，可能0就是成功吧
-	Url    string `json:"url"`    // 跳转 url。默认为 https://www.bilibili.com
+	IsNew        bool   `json:"is_new"`        // 是否为新注册用户。false：非新注册用户。true：新注册用户
+	Status       int    `json:"status"`        // 0。未知，可能0就是成功吧
+	Url          string `json:"url"`           // 跳转 url。默认为 https://www.bilibili.com
+	RefreshToken string `json:"refresh_token"` // 刷新refresh_token
 }
 
 // LoginWithSMS 使用短信验证码登录
@@ -172,7 +193,14 @@ func (c *Client) LoginWithSMS(param LoginWithSMSParam) (*LoginWithSMSResult, err
 		method = resty.MethodPost
 		url    = "https://passport.bilibili.com/x/passport-login/web/login/sms"
 	)
-	return execute[*LoginWithSMSResult](c, method, url, param)
+	result, err := execute[*LoginWithSMSResult](c, method, url, param)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil && result.Status == 0 {
+		err = c.loginSucceeded(result.RefreshToken)
+	}
+	return result, err
 }
 
 type QRCode struct {
@@ -215,7 +243,7 @@ type LoginWithQRCodeResult struct {
 
 // LoginWithQRCode 使用扫码登录。
 //
-// 该方法会阻塞直到扫码成功或者已经无法扫码。
+// 该方法会阻塞直到扫码成功或者已经无法扫码。可以通过 WithContext 设置超时或取消。
 func (c *Client) LoginWithQRCode(param LoginWithQRCodeParam) (*LoginWithQRCodeResult, error) {
 	const (
 		method = resty.MethodGet
@@ -229,9 +257,16 @@ func (c *Client) LoginWithQRCode(param LoginWithQRCodeParam) (*LoginWithQRCodeRe
 		if result.Code != 86090 && result.Code != 86101 {
 			// 86090：二维码已扫码未确认
 			// 86101：未扫码
+			if result.Code == 0 {
+				return result, c.loginSucceeded(result.RefreshToken)
+			}
 			return result, nil
 		}
-		time.Sleep(3 * time.Second) // 主站 3s 一次请求
+		select {
+		case <-c.Context().Done():
+			return nil, errors.WithStack(c.Context().Err())
+		case <-time.After(3 * time.Second): // 主站 3s 一次请求
+		}
 	}
 }
 
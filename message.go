@@ -82,10 +82,11 @@ func (c *Client) SendPrivateMessage(param SendPrivateMessageParam) (*SendPrivate
 		method = resty.MethodPost
 		url    = "https://api.vc.bilibili.com/web_im/v1/web_im/send_msg"
 	)
-	return execute[*SendPrivateMessageResult](c, method, url, param, fillCsrf(c), func(request *resty.Request) error {
-		request.SetQueryParam("msg[dev_id]", deviceId)
-		return nil
-	})
+	in := struct {
+		SendPrivateMessageParam
+		DevId string `json:"msg[dev_id]"`
+	}{param, deviceId}
+	return execute[*SendPrivateMessageResult](c, method, url, in, fillCsrf(c))
 }
 
 type GetPrivateMessageRecordsParam struct {
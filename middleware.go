@@ -0,0 +1,150 @@
+package bilibili
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Call 是一次接口调用的信息。一次调用可能因为重试而包含多次请求。
+type Call struct {
+	Context  context.Context // 调用使用的 context，中间件可以替换它，例如放入 tracing 的 span
+	Endpoint string          // 接口名，即接口地址的路径，例如 "/x/web-interface/view"
+	Method   string          // 请求方法
+	Url      string          // 接口地址，不含参数。是B站原本的地址，不受 SetHost 影响
+
+	// 以下字段在调用完成后填充
+	Query      url.Values    // 最后一次请求的 query 参数，csrf 等敏感参数已经被隐去
+	Code       int           // B站返回的错误码，成功为0。网络错误等没有返回错误码时也为0
+	StatusCode int           // 最后一次请求的HTTP状态码，没有收到响应时为0
+	Attempts   int           // 请求次数，包括重试
+	Cached     bool          // 是否命中了响应缓存，参见 Client.SetResponseCache
+	Proxy      string        // 最后一次请求使用的代理，参见 Client.SetProxyPool
+	Latency    time.Duration // 调用的总耗时，包括重试等待和限流等待
+
+	DecodeIssues []DecodeIssue // 响应与结构体定义不符的地方，只在 DecodeStrict 和 DecodeTolerant 模式下记录，参见 Client.SetDecodeMode
+
+	files map[string][]byte // 上传的文件内容，重试时重新发送
+}
+
+// uploadedFile 返回之前的请求中已经读取的上传文件，call 可以为 nil
+func (call *Call) uploadedFile(name string) ([]byte, bool) {
+	if call == nil {
+		return nil, false
+	}
+	data, ok := call.files[name]
+	return data, ok
+}
+
+// Invoker 执行一次调用
+type Invoker func(call *Call) error
+
+// Middleware 包装一次调用，可以用于日志、tracing、统计等。它应当调用 next 并返回 next 的错误：
+//
+//	func(next bilibili.Invoker) bilibili.Invoker {
+//		return func(call *bilibili.Call) error {
+//			err := next(call)
+//			requestCounter.WithLabelValues(call.Endpoint, strconv.Itoa(call.Code)).Inc()
+//			return err
+//		}
+//	}
+type Middleware func(next Invoker) Invoker
+
+// Use 添加中间件，先添加的中间件在外层。中间件包装的是包括重试在内的整个调用。
+func (c *Client) Use(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares[:len(c.middlewares):len(c.middlewares)], middlewares...)
+	return c
+}
+
+// SensitiveKeys 是 Redact 会隐去的参数名，不区分大小写
+var SensitiveKeys = []string{"csrf", "csrf_token", "SESSDATA", "bili_jct", "access_key", "refresh_token", "refresh_csrf", "password"}
+
+// Redact 返回 values 的拷贝，其中 SensitiveKeys 中的参数被替换为 "***"
+func Redact(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for k, v := range values {
+		if isSensitiveKey(k) {
+			redacted[k] = []string{"***"}
+		} else {
+			redacted[k] = append([]string(nil), v...)
+		}
+	}
+	return redacted
+}
+
+func isSensitiveKey(key string) bool {
+	for _, k := range SensitiveKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogMiddleware 返回一个记录每次调用的中间件，logf 可以是 log.Printf 等。记录的内容不包括 cookies，csrf 等参数会被隐去。
+func LogMiddleware(logf func(format string, v ...any)) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call *Call) error {
+			err := next(call)
+			if err != nil {
+				logf("bilibili: %s %s query=%s code=%d status=%d attempts=%d latency=%s err=%v",
+					call.Method, call.Url, call.Query.Encode(), call.Code, call.StatusCode, call.Attempts, call.Latency, err)
+			} else {
+				logf("bilibili: %s %s query=%s code=%d status=%d attempts=%d latency=%s",
+					call.Method, call.Url, call.Query.Encode(), call.Code, call.StatusCode, call.Attempts, call.Latency)
+			}
+			return err
+		}
+	}
+}
+
+type callKey struct{}
+
+// callFromContext 返回 ctx 所属的 Call，用于在请求中记录 Call 的信息
+func callFromContext(ctx context.Context) *Call {
+	call, _ := ctx.Value(callKey{}).(*Call)
+	return call
+}
+
+// call 经过中间件、重试策略和限流器发起一次调用，do 使用传入的 Client 发起一次请求
+func (c *Client) call(method, rawUrl string, do func(c *Client) error) error {
+	return c.callWith(method, rawUrl, func(c *Client, call *Call) error {
+		return c.withRetry(call, func() error { return do(c) })
+	})
+}
+
+// callWith 经过中间件发起一次调用，invoke 使用传入的 Client 完成调用，重试和限流由 invoke 自行处理
+func (c *Client) callWith(method, rawUrl string, invoke func(c *Client, call *Call) error) error {
+	call := &Call{Context: c.Context(), Endpoint: rawUrl, Method: method, Url: rawUrl}
+	if u, err := url.Parse(rawUrl); err == nil {
+		call.Endpoint = u.Path
+		u.RawQuery = ""
+		call.Url = u.String()
+	}
+	invoker := func(call *Call) error {
+		c2 := c.WithContext(context.WithValue(call.Context, callKey{}, call))
+		start := time.Now()
+		err := invoke(c2, call)
+		call.Latency = time.Since(start)
+		var e Error
+		if errors.As(err, &e) {
+			call.Code = e.Code
+		}
+		return err
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		invoker = c.middlewares[i](invoker)
+	}
+	return invoker(call)
+}
+
+// recordResponse 在 Call 中记录一次请求的参数和状态码
+func recordResponse(ctx context.Context, query url.Values, statusCode int) {
+	if call := callFromContext(ctx); call != nil {
+		call.Query = Redact(query)
+		call.StatusCode = statusCode
+	}
+}
@@ -0,0 +1,74 @@
+package bilibili_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+type traceKey struct{}
+
+func TestMiddleware(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var once sync.Once
+	server.Handle("/x/v2/history/toview/add", func(http.ResponseWriter, *http.Request) (any, error) {
+		err := bilibili.ErrNotFound
+		once.Do(func() { err = bilibili.Error{Code: -799, Message: "请求过于频繁，请稍后再试"} })
+		return nil, err
+	})
+
+	var order []string
+	var traceIds []any
+	var calls []*bilibili.Call
+	var logs []string
+	c := server.NewClient().
+		SetRetryPolicy(&bilibili.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}).
+		Use(func(next bilibili.Invoker) bilibili.Invoker {
+			return func(call *bilibili.Call) error {
+				order = append(order, "outer")
+				call.Context = context.WithValue(call.Context, traceKey{}, "trace-1")
+				err := next(call)
+				calls = append(calls, call)
+				return err
+			}
+		}, func(next bilibili.Invoker) bilibili.Invoker {
+			return func(call *bilibili.Call) error {
+				order = append(order, "inner")
+				traceIds = append(traceIds, call.Context.Value(traceKey{}))
+				return next(call)
+			}
+		}).
+		Use(bilibili.LogMiddleware(func(format string, v ...any) {
+			logs = append(logs, fmt.Sprintf(format, v...))
+		}))
+	session := server.NewSession(2)
+	c.SetCookies(session.Cookies())
+
+	err := c.AddToView(bilibili.VideoParam{Aid: 1})
+	if !bilibili.IsNotFound(err) {
+		t.Fatal("expected error code -404, got ", err)
+	}
+	if strings.Join(order, ",") != "outer,inner" || len(calls) != 1 || traceIds[0] != "trace-1" {
+		t.Fatal("middleware order not correct ", order, traceIds)
+	}
+	call := calls[0]
+	if call.Endpoint != "/x/v2/history/toview/add" || call.Method != http.MethodPost ||
+		call.Url != "https://api.bilibili.com/x/v2/history/toview/add" {
+		t.Fatal("call info not correct ", call)
+	}
+	if call.Code != -404 || call.StatusCode != 200 || call.Attempts != 2 || call.Latency <= 0 {
+		t.Fatal("call result not correct ", call)
+	}
+	if call.Query.Get("csrf") != "***" || len(logs) != 1 ||
+		strings.Contains(logs[0], session.BiliJct) || strings.Contains(logs[0], session.SESSDATA) {
+		t.Fatal("sensitive values should be redacted ", call.Query, logs)
+	}
+}
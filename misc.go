@@ -3,47 +3,32 @@ package bilibili
 import (
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
-var (
-	regBv   = regexp.MustCompile(`(?i)bv([\dA-Za-z]{10})`)
-	regLive = regexp.MustCompile(`^https://live.bilibili.com/(\d+)`)
-)
-
 // UnwrapShortUrl 解析短链接，传入一个完整的短链接。
 //
 // 第一个返回值如果是"bvid"，则第二个返回值是视频的bvid (string)。
 // 第一个返回值如果是"live"，则第二个返回值是直播间id (int)。
+// 其它类型的链接，第一个返回值是 URLKind.String()，第二个返回值是 ParsedURL。需要完整的解析结果时请使用 ResolveURL。
 func (c *Client) UnwrapShortUrl(shortUrl string) (string, any, error) {
-	resp, err := c.resty.R().Get(shortUrl)
-	if resp == nil {
-		return "", nil, errors.WithStack(err)
-	}
-	if resp.StatusCode() != 302 {
-		return "", nil, errors.Errorf("解析短链接失败，status code: %d", resp.StatusCode())
+	location, err := c.shortUrlLocation(shortUrl)
+	if err != nil {
+		return "", nil, err
 	}
-	url := resp.Header().Get("Location")
-	{
-		ret := regBv.FindString(url)
-		if len(ret) > 0 {
-			return "bvid", ret, nil
-		}
+	result, err := ParseURL(location)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "无法解析链接：%s", location)
 	}
-	{
-		ret := regLive.FindStringSubmatch(url)
-		if len(ret) > 0 {
-			rid, err := strconv.Atoi(ret[1])
-			if err != nil {
-				return "", nil, errors.WithStack(err)
-			}
-			return "live", rid, nil
-		}
+	switch result.Kind {
+	case URLVideo:
+		return "bvid", result.Video.Bvid, nil
+	case URLLive:
+		return "live", int(result.Id), nil
+	default:
+		return result.Kind.String(), result, nil
 	}
-	return "", nil, errors.New("无法解析链接：" + url)
 }
 
 // Now 获取服务器当前时间
@@ -62,38 +47,45 @@ func (c *Client) Now() (time.Time, error) {
 	return time.Unix(result.Now, 0), nil
 }
 
-// Av2Bv 将av号转换为bv号，返回格式为"BV1xxxxxxxxx"。
+const (
+	bvXorCode  = 0x1552356C4CDB
+	bvMaxAid   = 1 << 51
+	bvMaskCode = bvMaxAid - 1
+	bvAlphabet = "FcwAPNKTMug3GV5Lj7EJnHpWsx4tb8haYeviqBz6rkCy12mUSDQX9RdoZf"
+)
+
+// Av2Bv 将av号转换为bv号，返回格式为"BV1xxxxxxxxx"。不校验av号是否合法，需要校验时请使用 ParseVideoID。
 func Av2Bv(aid int) string {
-	const (
-		xorCode  = 0x1552356C4CDB
-		maxAid   = 1 << 51
-		alphabet = "FcwAPNKTMug3GV5Lj7EJnHpWsx4tb8haYeviqBz6rkCy12mUSDQX9RdoZf"
-	)
 	bvid := []byte("BV1000000000")
-	tmp := (maxAid | aid) ^ xorCode
+	tmp := (bvMaxAid | aid) ^ bvXorCode
 	for _, e := range []int{11, 10, 3, 8, 4, 6, 5, 7, 9} {
-		bvid[e] = alphabet[tmp%len(alphabet)]
-		tmp /= len(alphabet)
+		bvid[e] = bvAlphabet[tmp%len(bvAlphabet)]
+		tmp /= len(bvAlphabet)
 	}
 	return string(bvid)
 }
 
 // Bv2Av 将bv号转换为av号，传入的bv号格式为"BV1xxxxxxxxx"，前面的"BV"不区分大小写。
+// bv号不合法时返回0，需要知道错误原因时请使用 ParseVideoID。
 func Bv2Av(bvid string) int {
-	if len(bvid) != 12 {
-		panic("bvid 格式错误: " + bvid)
+	if len(bvid) < 2 || !strings.EqualFold(bvid[:2], "bv") {
+		return 0
 	}
-	const (
-		xorCode  = 0x1552356C4CDB
-		maskCode = 1<<51 - 1
-		alphabet = "FcwAPNKTMug3GV5Lj7EJnHpWsx4tb8haYeviqBz6rkCy12mUSDQX9RdoZf"
-	)
+	id, err := parseVideoNumber(bvid)
+	if err != nil {
+		return 0
+	}
+	return id.Aid
+}
+
+// bv2av 将格式为"BV1xxxxxxxxx"的bv号转换为av号，不做任何校验
+func bv2av(bvid string) int {
 	tmp := 0
 	for _, e := range []int{9, 7, 5, 6, 4, 8, 3, 10, 11} {
-		idx := strings.IndexByte(alphabet, bvid[e])
-		tmp = tmp*len(alphabet) + idx
+		idx := strings.IndexByte(bvAlphabet, bvid[e])
+		tmp = tmp*len(bvAlphabet) + idx
 	}
-	return (tmp & maskCode) ^ xorCode
+	return (tmp & bvMaskCode) ^ bvXorCode
 }
 
 type ZoneLocation struct {
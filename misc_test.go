@@ -9,4 +9,10 @@ func TestAvBv(t *testing.T) {
 	if 111298867365120 != Bv2Av("BV1L9Uoa9EUx") {
 		t.Fail()
 	}
+	// 不合法的bv号返回0，而不是 panic 或者错误的结果
+	for _, bvid := range []string{"", "BV17x411w7K", "BV17x411w7K0", "BV17x411wfKC", "av170001"} {
+		if aid := Bv2Av(bvid); aid != 0 {
+			t.Fatal(bvid, " expected 0, got ", aid)
+		}
+	}
 }
@@ -0,0 +1,292 @@
+package bilibili
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoAvailableAccount 表示 AccountPool 中所有的账号都在冷却中或者已经登录失效
+var ErrNoAvailableAccount = errors.New("没有可用的账号")
+
+// AccountState 是账号的健康状态
+type AccountState int
+
+const (
+	AccountHealthy     AccountState = iota // 正常
+	AccountCoolingDown                     // 被风控或限流，冷却中
+	AccountLoggedOut                       // 登录已失效，需要重新登录后调用 AccountPool.Reset
+)
+
+func (s AccountState) String() string {
+	switch s {
+	case AccountHealthy:
+		return "healthy"
+	case AccountCoolingDown:
+		return "cooling down"
+	case AccountLoggedOut:
+		return "logged out"
+	default:
+		return "unknown"
+	}
+}
+
+// AccountStatus 是账号的状态和统计
+type AccountStatus struct {
+	Name          string
+	State         AccountState
+	CooldownUntil time.Time // 冷却结束的时间，State 为 AccountCoolingDown 时有效
+	LastLimitedAt time.Time // 最近一次被风控或限流的时间
+	LastError     error     // 最近一次失败的错误
+	Requests      int64     // 调用次数
+	Failures      int64     // 失败的调用次数
+}
+
+// PoolStrategy 是 AccountPool 选择账号的策略
+type PoolStrategy int
+
+const (
+	RoundRobin           PoolStrategy = iota // 依次轮流使用每个账号
+	LeastRecentlyLimited                     // 优先使用最久没有被风控或限流的账号
+)
+
+// AccountPool 管理多个已登录的 Client，每个 Client 有自己的 cookies 和 WBI 状态。
+//
+// 账号收到 -412、-352、-799 等风控或限流错误时会进入冷却，冷却期间不会被选中；收到 -101 时会被标记为登录失效。
+// 一般用于只读的接口，例如：
+//
+//	pool := bilibili.NewAccountPool(bilibili.LeastRecentlyLimited).
+//		Add("alice", client1).
+//		Add("bob", client2)
+//	err := pool.Do(func(c *bilibili.Client) error {
+//		info, err = c.GetUserCard(bilibili.GetUserCardParam{Mid: mid})
+//		return err
+//	})
+type AccountPool struct {
+	mu       sync.Mutex
+	accounts []*poolAccount
+	strategy PoolStrategy
+	cooldown time.Duration
+	next     int
+	now      func() time.Time
+	tracked  map[*Client]bool // 已经添加了 track 中间件的 Client
+}
+
+type poolAccount struct {
+	client *Client
+	status AccountStatus
+}
+
+// NewAccountPool 返回一个空的 AccountPool，默认的冷却时间为5分钟
+func NewAccountPool(strategy PoolStrategy) *AccountPool {
+	return &AccountPool{
+		strategy: strategy,
+		cooldown: 5 * time.Minute,
+		now:      time.Now,
+	}
+}
+
+// SetCooldown 设置账号被风控或限流后的冷却时间
+func (p *AccountPool) SetCooldown(cooldown time.Duration) *AccountPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cooldown = cooldown
+	return p
+}
+
+// Add 添加一个账号，name 用于在 Status 中区分账号。同名的账号会被替换。
+//
+// AccountPool 会通过中间件记录 client 每次调用的结果，因此直接使用 client 发起的调用也会影响账号的状态。
+// 同一个 client 只会添加一次中间件，client 被移除后中间件不再记录。
+func (p *AccountPool) Add(name string, client *Client) *AccountPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.tracked[client] {
+		if p.tracked == nil {
+			p.tracked = make(map[*Client]bool, 4)
+		}
+		p.tracked[client] = true
+		client.Use(p.track(client))
+	}
+	a := &poolAccount{client: client, status: AccountStatus{Name: name}}
+	for i, a0 := range p.accounts {
+		if a0.status.Name == name {
+			p.accounts[i] = a
+			return p
+		}
+	}
+	p.accounts = append(p.accounts, a)
+	return p
+}
+
+// Remove 移除一个账号
+func (p *AccountPool) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, a := range p.accounts {
+		if a.status.Name == name {
+			p.accounts = append(p.accounts[:i], p.accounts[i+1:]...)
+			return
+		}
+	}
+}
+
+// Client 返回 name 对应的 Client，不存在时返回 nil
+func (p *AccountPool) Client(name string) *Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, a := range p.accounts {
+		if a.status.Name == name {
+			return a.client
+		}
+	}
+	return nil
+}
+
+// Reset 将账号恢复为正常状态，例如在重新登录之后
+func (p *AccountPool) Reset(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, a := range p.accounts {
+		if a.status.Name == name {
+			a.status.State = AccountHealthy
+			a.status.CooldownUntil = time.Time{}
+		}
+	}
+}
+
+// Status 返回所有账号的状态
+func (p *AccountPool) Status() []AccountStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	status := make([]AccountStatus, 0, len(p.accounts))
+	for _, a := range p.accounts {
+		p.refreshState(a, now)
+		status = append(status, a.status)
+	}
+	return status
+}
+
+// Get 按照策略选择一个可用的 Client，没有可用的账号时返回 ErrNoAvailableAccount
+func (p *AccountPool) Get() (*Client, error) {
+	a := p.pick(nil)
+	if a == nil {
+		return nil, errors.WithStack(ErrNoAvailableAccount)
+	}
+	return a.client, nil
+}
+
+// Do 选择一个可用的账号执行 fn。如果 fn 返回了风控、限流或者未登录的错误，会换一个账号再次执行，直到所有账号都尝试过。
+func (p *AccountPool) Do(fn func(c *Client) error) error {
+	tried := make(map[*poolAccount]bool, 4)
+	var lastErr error
+	for {
+		a := p.pick(tried)
+		if a == nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return errors.WithStack(ErrNoAvailableAccount)
+		}
+		err := fn(a.client)
+		if !shouldSwitchAccount(err) {
+			return err
+		}
+		tried[a] = true
+		lastErr = err
+	}
+}
+
+func shouldSwitchAccount(err error) bool {
+	return IsRejected(err) || IsNotLoggedIn(err)
+}
+
+// pick 选择一个不在 exclude 中的可用账号
+func (p *AccountPool) pick(exclude map[*poolAccount]bool) *poolAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := len(p.accounts)
+	var picked *poolAccount
+	pickedIndex := 0
+	for i := 0; i < n; i++ {
+		index := (p.next + i) % n
+		a := p.accounts[index]
+		p.refreshState(a, now)
+		if a.status.State != AccountHealthy || exclude[a] {
+			continue
+		}
+		if picked == nil || p.strategy == LeastRecentlyLimited && a.status.LastLimitedAt.Before(picked.status.LastLimitedAt) {
+			picked, pickedIndex = a, index
+		}
+		if p.strategy == RoundRobin {
+			break
+		}
+	}
+	if picked != nil {
+		p.next = (pickedIndex + 1) % n
+	}
+	return picked
+}
+
+// refreshState 冷却结束后恢复为正常状态，调用时需要持有锁
+func (p *AccountPool) refreshState(a *poolAccount, now time.Time) {
+	if a.status.State == AccountCoolingDown && !now.Before(a.status.CooldownUntil) {
+		a.status.State = AccountHealthy
+		a.status.CooldownUntil = time.Time{}
+	}
+}
+
+// account 返回 client 对应的账号，client 不在 AccountPool 中时返回 nil。调用时需要持有锁
+func (p *AccountPool) account(client *Client) *poolAccount {
+	for _, a := range p.accounts {
+		if a.client == client {
+			return a
+		}
+	}
+	return nil
+}
+
+// track 返回记录 client 每次调用结果的中间件，client 不在 AccountPool 中时不记录
+func (p *AccountPool) track(client *Client) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call *Call) error {
+			err := next(call)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			a := p.account(client)
+			if a == nil {
+				return err
+			}
+			a.status.Requests++
+			if err == nil {
+				return nil
+			}
+			a.status.Failures++
+			a.status.LastError = err
+			now := p.now()
+			switch {
+			case IsNotLoggedIn(err):
+				a.status.State = AccountLoggedOut
+			case IsRejected(err):
+				a.status.LastLimitedAt = now
+				if a.status.State != AccountLoggedOut {
+					a.status.State = AccountCoolingDown
+					a.status.CooldownUntil = now.Add(p.cooldown)
+				}
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package bilibili_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestAccountPool(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var mu sync.Mutex
+	errs := map[string]error{} // 账号名 -> zone 接口返回的错误
+	setError := func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[name] = err
+	}
+	names := map[int]string{}
+	server.Handle("/x/web-interface/zone", func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := names[server.Session(r).Mid]
+		if err := errs[name]; err != nil {
+			return nil, err
+		}
+		return bilibili.ZoneLocation{Addr: name}, nil
+	})
+
+	now := time.Unix(1700000000, 0)
+	pool := bilibili.NewAccountPool(bilibili.RoundRobin).SetCooldown(time.Minute)
+	pool.SetNow(func() time.Time { return now })
+	for i, name := range []string{"a", "b", "c"} {
+		names[i+1] = name
+		c := server.NewClient()
+		c.SetCookies(server.NewSession(i + 1).Cookies())
+		pool.Add(name, c)
+	}
+
+	getAddr := func() string {
+		var addr string
+		err := pool.Do(func(c *bilibili.Client) error {
+			zone, err := c.GetZoneLocation()
+			if err == nil {
+				addr = zone.Addr
+			}
+			return err
+		})
+		if err != nil {
+			return err.Error()
+		}
+		return addr
+	}
+	for _, expected := range []string{"a", "b", "c", "a"} {
+		if addr := getAddr(); addr != expected {
+			t.Fatalf("expected account %s, got %s", expected, addr)
+		}
+	}
+
+	setError("b", bilibili.Error{Code: -412, Message: "请求被拦截"})
+	setError("c", bilibili.ErrNotLoggedIn)
+	if addr := getAddr(); addr != "a" {
+		t.Fatal("should switch to a healthy account, got ", addr)
+	}
+	status := pool.Status()
+	if status[1].State != bilibili.AccountCoolingDown || status[2].State != bilibili.AccountLoggedOut || status[0].Requests != 3 {
+		t.Fatal("account status not correct ", status)
+	}
+
+	now = now.Add(time.Second)
+	setError("a", bilibili.Error{Code: -352, Message: "风控校验失败"})
+	if _, err := pool.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if addr := getAddr(); addr != "错误码: -352, 错误信息: 风控校验失败" {
+		t.Fatal("should return the last error when all accounts fail, got ", addr)
+	}
+	if _, err := pool.Get(); err == nil {
+		t.Fatal("expected ErrNoAvailableAccount")
+	}
+
+	// 冷却结束后恢复，优先使用最久没有被限流的账号
+	now = now.Add(2 * time.Minute)
+	setError("a", nil)
+	setError("b", nil)
+	pool.SetStrategy(bilibili.LeastRecentlyLimited)
+	if addr := getAddr(); addr != "b" {
+		t.Fatal("least recently limited account should be used, got ", addr)
+	}
+	pool.Reset("c")
+	if status = pool.Status(); status[2].State != bilibili.AccountHealthy {
+		t.Fatal("Reset should restore the account ", status)
+	}
+}
+
+func TestAccountPoolAddRemove(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	c := server.NewClient()
+	pool := bilibili.NewAccountPool(bilibili.RoundRobin)
+
+	// 重复添加同一个 Client 不会重复记录
+	pool.Add("a", c).Add("a", c)
+	if _, err := c.GetZoneLocation(); err != nil {
+		t.Fatal(err)
+	}
+	if status := pool.Status(); len(status) != 1 || status[0].Requests != 1 {
+		t.Fatal("requests should be counted once ", status)
+	}
+
+	// 移除后不再记录，再次添加时重新开始统计
+	pool.Remove("a")
+	if _, err := c.GetZoneLocation(); err != nil {
+		t.Fatal(err)
+	}
+	pool.Add("a", c)
+	if _, err := c.GetZoneLocation(); err != nil {
+		t.Fatal(err)
+	}
+	if status := pool.Status(); len(status) != 1 || status[0].Requests != 1 {
+		t.Fatal("removed account should not be tracked ", status)
+	}
+	if n := c.Middlewares(); n != 1 {
+		t.Fatal("middleware should be added once, got ", n)
+	}
+}
@@ -0,0 +1,172 @@
+package bilibili_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+// newProxyServer 返回一个直接响应请求的“代理”，zone 接口返回的 addr 为 name，可以通过 setStatus 令它返回HTTP错误
+func newProxyServer(name string) (server *bilibilitest.Server, setStatus func(statusCode int)) {
+	var mu sync.Mutex
+	status := 0
+	server = bilibilitest.NewServer()
+	server.Handle("/x/web-interface/zone", func(http.ResponseWriter, *http.Request) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if status != 0 {
+			return nil, bilibili.StatusError{StatusCode: status}
+		}
+		return bilibili.ZoneLocation{Addr: name}, nil
+	})
+	return server, func(statusCode int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = statusCode
+	}
+}
+
+func TestProxyPool(t *testing.T) {
+	a, setStatusA := newProxyServer("a")
+	b, setStatusB := newProxyServer("b")
+	defer a.Close()
+	defer b.Close()
+
+	now := time.Unix(1700000000, 0)
+	pool, err := bilibili.NewProxyPool(bilibili.ProxyRoundRobin, a.URL, b.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetNow(func() time.Time { return now })
+	c := bilibili.New().SetAllHosts("http://bilibili.test")
+	if err = c.SetProxyPool(pool); err != nil {
+		t.Fatal(err)
+	}
+	var proxies []string
+	c.Use(func(next bilibili.Invoker) bilibili.Invoker {
+		return func(call *bilibili.Call) error {
+			err := next(call)
+			proxies = append(proxies, call.Proxy)
+			return err
+		}
+	})
+	getAddr := func(c *bilibili.Client) string {
+		zone, err := c.GetZoneLocation()
+		if err != nil {
+			return err.Error()
+		}
+		return zone.Addr
+	}
+
+	for _, expected := range []string{"a", "b", "a"} {
+		if addr := getAddr(c); addr != expected {
+			t.Fatalf("expected proxy %s, got %s", expected, addr)
+		}
+	}
+	if proxies[1] != b.URL {
+		t.Fatal("Call.Proxy not recorded ", proxies)
+	}
+
+	// b 返回 412 后进入冷却，重试会换用 a
+	setStatusB(http.StatusPreconditionFailed)
+	c.SetRetryPolicy(&bilibili.RetryPolicy{MaxAttempts: 2})
+	if addr := getAddr(c); addr != "a" {
+		t.Fatal("should fail over to a, got ", addr)
+	}
+	if status := pool.Status(); status[1].Healthy || status[1].Failures != 1 {
+		t.Fatal("b should be unhealthy ", status)
+	}
+	if addr := getAddr(c); addr != "a" {
+		t.Fatal("b is cooling down, got ", addr)
+	}
+
+	// 所有代理都不可用
+	setStatusA(http.StatusPreconditionFailed)
+	_ = getAddr(c)
+	if _, err = c.GetZoneLocation(); !errors.Is(err, bilibili.ErrNoAvailableProxy) {
+		t.Fatal("expected ErrNoAvailableProxy, got ", err)
+	}
+
+	// 冷却结束后恢复，同一个账号总是使用同一个代理
+	now = now.Add(2 * time.Minute)
+	setStatusA(0)
+	setStatusB(0)
+	pool.SetStrategy(bilibili.ProxySticky)
+	c.SetRawCookies("DedeUserID=12345")
+	first := getAddr(c)
+	for i := 0; i < 3; i++ {
+		if addr := getAddr(c); addr != first {
+			t.Fatalf("sticky proxy changed from %s to %s", first, addr)
+		}
+	}
+
+	// 获取 wbi keys 的请求也经过代理
+	pool.SetStrategy(bilibili.ProxyFailover)
+	const navPath = "/x/web-interface/nav"
+	navA, navB := a.RequestCount(navPath), b.RequestCount(navPath)
+	if err = c.Wbi().Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.RequestCount(navPath) != navA+1 || b.RequestCount(navPath) != navB {
+		t.Fatal("nav request should go through proxy a")
+	}
+}
+
+func TestRemoveProxyPool(t *testing.T) {
+	direct, _ := newProxyServer("direct")
+	defer direct.Close()
+	a, _ := newProxyServer("a")
+	defer a.Close()
+
+	c := bilibili.New().SetAllHosts("http://bilibili.test")
+	transport, err := c.Resty().Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	directURL, _ := url.Parse(direct.URL)
+	transport.Proxy = http.ProxyURL(directURL)
+	pool, err := bilibili.NewProxyPool(bilibili.ProxyRoundRobin, a.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*bilibili.ProxyPool{pool, pool, nil} {
+		if err = c.SetProxyPool(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 取消代理池后恢复原来的 Proxy
+	if zone, err := c.GetZoneLocation(); err != nil || zone.Addr != "direct" {
+		t.Fatal("previous proxy should be restored ", zone, err)
+	}
+	if err = c.SetProxyPool(nil); err != nil || transport.Proxy == nil {
+		t.Fatal("removing proxy pool twice should keep the previous proxy ", err)
+	}
+}
+
+func TestProxyPoolCheckHealth(t *testing.T) {
+	bad, setStatus := newProxyServer("bad")
+	defer bad.Close()
+	setStatus(http.StatusPreconditionFailed)
+	good, _ := newProxyServer("good")
+	defer good.Close()
+
+	pool, err := bilibili.NewProxyPool(bilibili.ProxyFailover, bad.URL, good.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.CheckHealth(context.Background(), "http://bilibili.test/x/web-interface/zone")
+	if status := pool.Status(); status[0].Healthy || !status[1].Healthy {
+		t.Fatal("health check result not correct ", status)
+	}
+
+	if _, err = bilibili.NewProxyPool(bilibili.ProxyRoundRobin, "ftp://127.0.0.1"); err == nil {
+		t.Fatal("unsupported scheme should be rejected")
+	}
+}
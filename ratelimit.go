@@ -0,0 +1,229 @@
+package bilibili
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRateLimitExceeded 表示请求超出了 RateLimiter 的限制。只有在 RateLimiter 设置为 FailFast 时才会返回这个错误。
+var ErrRateLimitExceeded = errors.New("超出客户端限流")
+
+// Limit 是令牌桶的参数
+type Limit struct {
+	Rate  float64 // 每秒产生的令牌数，即每秒允许的请求数。小于等于0表示不限制
+	Burst int     // 令牌桶的容量，即允许的突发请求数。小于1时视为1
+}
+
+// Every 返回每 interval 允许一次请求的 Limit
+func Every(interval time.Duration, burst int) Limit {
+	return Limit{Rate: float64(time.Second) / float64(interval), Burst: burst}
+}
+
+// RateLimitStats 是限流的统计数据
+type RateLimitStats struct {
+	Requests  int64         // 经过该限流器的请求数
+	Throttled int64         // 因为限流而等待的请求数
+	Rejected  int64         // 因为限流而直接失败的请求数（FailFast）
+	Waited    time.Duration // 因为限流而等待的总时长
+}
+
+// RateLimiter 是客户端的令牌桶限流器，可以分别为每个 Host 和每个接口设置限制，一个请求需要同时满足两者。
+// 它是并发安全的，也可以被多个 Client 共享，例如同一个 IP 下的多个账号。
+//
+//	limiter := bilibili.NewRateLimiter().
+//		SetHostLimit(bilibili.HostApi, bilibili.Limit{Rate: 5, Burst: 10}).
+//		SetEndpointLimit("/x/space/wbi/arc/search", bilibili.Every(2*time.Second, 1))
+//	client.SetRateLimiter(limiter)
+type RateLimiter struct {
+	mu        sync.Mutex
+	hosts     map[Host]*bucket
+	endpoints map[string]*bucket // 接口路径 -> 令牌桶
+	failFast  bool
+	now       func() time.Time
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+	stats  RateLimitStats
+}
+
+// NewRateLimiter 返回一个没有任何限制的 RateLimiter
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		hosts:     make(map[Host]*bucket, len(AllHosts)),
+		endpoints: make(map[string]*bucket, 8),
+		now:       time.Now,
+	}
+}
+
+// SetHostLimit 限制 host 下所有接口的总请求速率。limit.Rate 小于等于0表示取消限制
+func (l *RateLimiter) SetHostLimit(host Host, limit Limit) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	host = Host(strings.TrimSuffix(string(host), "/"))
+	if limit.Rate <= 0 {
+		delete(l.hosts, host)
+	} else {
+		l.hosts[host] = l.newBucket(limit)
+	}
+	return l
+}
+
+// SetEndpointLimit 限制单个接口的请求速率，path 是不含域名和参数的路径，例如 "/x/relation/followers"。
+// limit.Rate 小于等于0表示取消限制
+func (l *RateLimiter) SetEndpointLimit(path string, limit Limit) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit.Rate <= 0 {
+		delete(l.endpoints, path)
+	} else {
+		l.endpoints[path] = l.newBucket(limit)
+	}
+	return l
+}
+
+// SetFailFast 设置超出限制时的行为。默认为 false，即等待直到可以发起请求；
+// 为 true 时不等待，直接返回 ErrRateLimitExceeded。
+func (l *RateLimiter) SetFailFast(failFast bool) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.failFast = failFast
+	return l
+}
+
+// Stats 返回每个限制的统计数据，key 为 Host 或者接口路径
+func (l *RateLimiter) Stats() map[string]RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[string]RateLimitStats, len(l.hosts)+len(l.endpoints))
+	for host, b := range l.hosts {
+		stats[string(host)] = b.stats
+	}
+	for path, b := range l.endpoints {
+		stats[path] = b.stats
+	}
+	return stats
+}
+
+func (l *RateLimiter) newBucket(limit Limit) *bucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &bucket{limit: limit, tokens: float64(limit.Burst), last: l.now()}
+}
+
+// advance 补充令牌
+func (b *bucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+		b.last = now
+	}
+}
+
+// delay 返回取得一个令牌需要等待的时间，不消耗令牌
+func (b *bucket) delay() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+// buckets 返回 rawUrl 需要经过的所有令牌桶
+func (l *RateLimiter) buckets(rawUrl string) []*bucket {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil
+	}
+	buckets := make([]*bucket, 0, 2)
+	if b, ok := l.hosts[Host(u.Scheme+"://"+u.Host)]; ok {
+		buckets = append(buckets, b)
+	}
+	if b, ok := l.endpoints[u.Path]; ok {
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+// reserve 为一次请求预留令牌，返回需要等待的时间。FailFast 且需要等待时不消耗令牌，返回 ErrRateLimitExceeded
+func (l *RateLimiter) reserve(rawUrl string) ([]*bucket, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := l.buckets(rawUrl)
+	now := l.now()
+	var wait time.Duration
+	for _, b := range buckets {
+		b.advance(now)
+		b.stats.Requests++
+		if d := b.delay(); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 && l.failFast {
+		for _, b := range buckets {
+			b.stats.Rejected++
+		}
+		return nil, 0, errors.WithStack(ErrRateLimitExceeded)
+	}
+	for _, b := range buckets {
+		b.tokens--
+		if wait > 0 {
+			b.stats.Throttled++
+			b.stats.Waited += wait
+		}
+	}
+	return buckets, wait, nil
+}
+
+// cancel 归还预留的令牌
+func (l *RateLimiter) cancel(buckets []*bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range buckets {
+		b.tokens++
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+	}
+}
+
+// SetRateLimiter 设置限流器，传入 nil 表示不限流。重试的每一次请求都会经过限流器。
+func (c *Client) SetRateLimiter(limiter *RateLimiter) *Client {
+	c.rateLimiter = limiter
+	return c
+}
+
+// throttle 按照限流器的设置等待，直到可以请求 url
+func (c *Client) throttle(url string) error {
+	if c.rateLimiter == nil {
+		return nil
+	}
+	buckets, wait, err := c.rateLimiter.reserve(url)
+	if err != nil || wait <= 0 {
+		return err
+	}
+	ctx := c.Context()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		c.rateLimiter.cancel(buckets)
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package bilibili_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := bilibili.NewRateLimiter()
+	l.SetNow(func() time.Time { return now })
+	l.SetHostLimit(bilibili.HostApi, bilibili.Limit{Rate: 10, Burst: 2})
+	l.SetEndpointLimit("/x/relation/followers", bilibili.Every(time.Second, 1))
+
+	const followers = "https://api.bilibili.com/x/relation/followers?vmid=2"
+	const view = "https://api.bilibili.com/x/web-interface/view"
+	const live = "https://api.live.bilibili.com/room/v1/Room/get_info"
+
+	expected := []struct {
+		url  string
+		wait time.Duration
+	}{
+		{followers, 0},                 // host 2 -> 1, endpoint 1 -> 0
+		{view, 0},                      // host 1 -> 0
+		{view, 100 * time.Millisecond}, // host 0 -> -1
+		{followers, time.Second},       // endpoint 0 -> -1
+		{live, 0},                      // 没有限制
+		{view, 300 * time.Millisecond}, // host -2 -> -3
+	}
+	for i, e := range expected {
+		wait, err := l.Reserve(e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != e.wait {
+			t.Fatalf("reserve #%d wait %s, expected %s", i, wait, e.wait)
+		}
+	}
+
+	now = now.Add(10 * time.Second)
+	l.SetFailFast(true)
+	for i := 0; i < 2; i++ {
+		if _, err := l.Reserve(view); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Reserve(view); !errors.Is(err, bilibili.ErrRateLimitExceeded) {
+		t.Fatal("expected ErrRateLimitExceeded, got ", err)
+	}
+
+	stats := l.Stats()
+	if s := stats[string(bilibili.HostApi)]; s.Requests != 8 || s.Throttled != 3 || s.Rejected != 1 || s.Waited != 1400*time.Millisecond {
+		t.Fatal("host stats not correct ", s)
+	}
+	if s := stats["/x/relation/followers"]; s.Requests != 2 || s.Throttled != 1 {
+		t.Fatal("endpoint stats not correct ", s)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+
+	limiter := bilibili.NewRateLimiter().SetHostLimit(bilibili.HostApi, bilibili.Every(50*time.Millisecond, 1))
+	c := server.NewClient().SetRateLimiter(limiter)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetZoneLocation(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatal("requests should be throttled, elapsed ", elapsed)
+	}
+}
@@ -0,0 +1,199 @@
+package bilibili
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// correspondPublicKey 是生成 correspondPath 使用的公钥，由B站网页端固定写死
+const correspondPublicKey = `-----BEGIN PUBLIC KEY-----
+MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDLgd2OAkcGVtoE3ThUREbio0Eg
+Uc/prcajMKXvkCKFCWhJYJcLkcM2DKKcSeFpD/j6Boy538YXnR6VhcuUJOhH2x71
+nzPjfdTcqMz7djHum0qSZA0AyCBDABUqCrfNgCiJ00Ra7GmRj+YCK1NJEuewlb40
+JNrRuoEUXpabUzGB8QIDAQAB
+-----END PUBLIC KEY-----`
+
+var regRefreshCsrf = regexp.MustCompile(`<div id="1-name">(.+?)</div>`)
+
+type CookieRefreshInfo struct {
+	Refresh   bool  `json:"refresh"`   // 是否应该刷新cookies
+	Timestamp int64 `json:"timestamp"` // 当前毫秒时间戳，用于生成 correspondPath
+}
+
+// GetCookieRefreshInfo 检查是否需要刷新cookies
+func (c *Client) GetCookieRefreshInfo() (*CookieRefreshInfo, error) {
+	const (
+		method = resty.MethodGet
+		url    = "https://passport.bilibili.com/x/passport-login/web/cookie/info"
+	)
+	return execute[*CookieRefreshInfo](c, method, url, nil, c.fillRefreshCsrf(url))
+}
+
+// CorrespondPath 使用 GetCookieRefreshInfo 返回的时间戳生成 correspondPath，用于获取 refresh_csrf
+func CorrespondPath(timestamp int64) (string, error) {
+	pk, err := parsePublicKey(correspondPublicKey)
+	if err != nil {
+		return "", err
+	}
+	cipherText, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pk, []byte(fmt.Sprintf("refresh_%d", timestamp)), nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return hex.EncodeToString(cipherText), nil
+}
+
+// GetRefreshCsrf 获取刷新cookies需要的 refresh_csrf，correspondPath 使用 CorrespondPath 生成，有效期很短
+func (c *Client) GetRefreshCsrf(correspondPath string) (refreshCsrf string, err error) {
+	const method = resty.MethodGet
+	rawUrl := "https://www.bilibili.com/correspond/1/" + correspondPath
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	err = c.call(method, rawUrl, func(c *Client) error {
+		r := c.resty.R().SetContext(c.Context()).SetHeader("Accept", "text/html")
+		r.Cookies = c.cookies.cookiesFor(u, true) // 允许使用已经过期的cookie
+		resp, err := r.Execute(method, c.resolveUrl(rawUrl))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		recordResponse(r.Context(), r.QueryParam, resp.StatusCode())
+		if resp.StatusCode() != 200 {
+			return errors.WithStack(StatusError{StatusCode: resp.StatusCode()})
+		}
+		ret := regRefreshCsrf.FindSubmatch(resp.Body())
+		if len(ret) == 0 {
+			return errors.New("获取refresh_csrf失败")
+		}
+		refreshCsrf = string(ret[1])
+		return nil
+	})
+	return
+}
+
+type RefreshCookieParam struct {
+	RefreshCsrf  string `json:"refresh_csrf"`                      // 使用 GetRefreshCsrf 获取
+	Source       string `json:"source" request:"default=main_web"` // 访问来源。默认为 main_web
+	RefreshToken string `json:"refresh_token"`                     // 当前的 refresh_token
+}
+
+type RefreshCookieResult struct {
+	Status       int    `json:"status"`        // 0
+	Message      string `json:"message"`       // 空
+	RefreshToken string `json:"refresh_token"` // 新的 refresh_token
+}
+
+// RefreshCookie 刷新cookies，成功后新的cookies会自动设置，旧的 refresh_token 需要通过 ConfirmRefreshCookie 确认后才会失效。
+//
+// 一般直接使用 RefreshCookies 即可。
+func (c *Client) RefreshCookie(param RefreshCookieParam) (*RefreshCookieResult, error) {
+	const (
+		method = resty.MethodPost
+		url    = "https://passport.bilibili.com/x/passport-login/web/cookie/refresh"
+	)
+	return execute[*RefreshCookieResult](c, method, url, param, c.fillRefreshCsrf(url))
+}
+
+type ConfirmRefreshCookieParam struct {
+	RefreshToken string `json:"refresh_token"` // 刷新前的 refresh_token
+}
+
+// ConfirmRefreshCookie 确认更新cookies，使旧的 refresh_token 失效，需要使用新的cookies调用
+func (c *Client) ConfirmRefreshCookie(param ConfirmRefreshCookieParam) error {
+	const (
+		method = resty.MethodPost
+		url    = "https://passport.bilibili.com/x/passport-login/web/confirm/refresh"
+	)
+	_, err := execute[any](c, method, url, param, c.fillRefreshCsrf(url))
+	return err
+}
+
+// RefreshCookies 使用登录时返回的 refresh_token 完成一次完整的cookies刷新，并更新 refresh_token。
+// 如果设置了 SessionStore，新的登录状态会被自动保存。
+//
+// 多个 goroutine 同时调用时只会刷新一次。
+func (c *Client) RefreshCookies() error {
+	_, err, _ := c.session.refreshGroup.Do("refresh", func() (any, error) {
+		return nil, c.doRefreshCookies()
+	})
+	return err
+}
+
+// RefreshCookiesIfNeeded 在B站认为需要刷新时刷新cookies，返回是否进行了刷新
+func (c *Client) RefreshCookiesIfNeeded() (bool, error) {
+	info, err := c.GetCookieRefreshInfo()
+	if err != nil {
+		return false, err
+	}
+	if !info.Refresh {
+		return false, nil
+	}
+	return true, c.RefreshCookies()
+}
+
+// SetAutoRefreshCookies 设置是否自动刷新cookies。开启后，需要登录的请求在发现cookies已经过期时，会先使用 refresh_token 刷新cookies。
+func (c *Client) SetAutoRefreshCookies(enable bool) *Client {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	c.session.autoRefresh = enable
+	return c
+}
+
+func (c *Client) doRefreshCookies() error {
+	refreshToken := c.RefreshToken()
+	if len(refreshToken) == 0 {
+		return errors.WithStack(ErrNoRefreshToken)
+	}
+	info, err := c.GetCookieRefreshInfo()
+	if err != nil {
+		return err
+	}
+	correspondPath, err := CorrespondPath(info.Timestamp)
+	if err != nil {
+		return err
+	}
+	refreshCsrf, err := c.GetRefreshCsrf(correspondPath)
+	if err != nil {
+		return err
+	}
+	result, err := c.RefreshCookie(RefreshCookieParam{RefreshCsrf: refreshCsrf, RefreshToken: refreshToken})
+	if err != nil {
+		return err
+	}
+	if err = c.SetRefreshToken(result.RefreshToken); err != nil {
+		return err
+	}
+	return c.ConfirmRefreshCookie(ConfirmRefreshCookieParam{RefreshToken: refreshToken})
+}
+
+// tryAutoRefresh 在开启了自动刷新时刷新cookies，返回是否刷新成功
+func (c *Client) tryAutoRefresh() bool {
+	c.session.mu.Lock()
+	enabled := c.session.autoRefresh && len(c.session.refreshToken) > 0
+	c.session.mu.Unlock()
+	return enabled && c.RefreshCookies() == nil
+}
+
+// fillRefreshCsrf 刷新cookies时使用的 csrf 和cookies，允许使用已经过期的 bili_jct 等cookie
+func (c *Client) fillRefreshCsrf(rawUrl string) paramHandler {
+	return func(r *resty.Request) error {
+		csrf := c.lookupCookie("bili_jct", true)
+		if len(csrf) == 0 {
+			return errors.WithStack(ErrNotLoggedIn)
+		}
+		r.SetQueryParam("csrf", csrf)
+		if u, err := url.Parse(rawUrl); err == nil {
+			r.Cookies = c.cookies.cookiesFor(u, true)
+		}
+		return nil
+	}
+}
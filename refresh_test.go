@@ -0,0 +1,65 @@
+package bilibili_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestCookieRefresh(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	session := server.NewSession(2)
+	client.SetCookies(session.Cookies())
+	if err := client.SetRefreshToken(session.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := client.RefreshCookiesIfNeeded()
+	if err != nil || refreshed {
+		t.Fatal("session should not need refresh ", err)
+	}
+
+	server.ExpireSession(session)
+	refreshed, err = client.RefreshCookiesIfNeeded()
+	if err != nil || !refreshed {
+		t.Fatal("session should be refreshed ", err)
+	}
+	if client.RefreshToken() == session.RefreshToken || server.HasSession(session.SESSDATA) {
+		t.Fatal("refresh_token should be rotated and the old session invalidated")
+	}
+	if err = client.RefreshCookies(); err != nil {
+		t.Fatal("refresh again with the new refresh_token ", err)
+	}
+}
+
+func TestAutoRefreshCookies(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.Handle("/x/v2/history/toview/add", func(http.ResponseWriter, *http.Request) (any, error) {
+		return nil, nil
+	})
+	client := server.NewClient()
+	session := server.NewSession(2)
+	cookies := session.Cookies()
+	for _, cookie := range cookies {
+		cookie.Expires = time.Now().Add(-time.Hour)
+	}
+	client.SetCookies(cookies)
+	_ = client.SetRefreshToken(session.RefreshToken)
+
+	if err := client.AddToView(bilibili.VideoParam{Aid: 170001}); !bilibili.IsNotLoggedIn(err) {
+		t.Fatal("expected error code -101, got ", err)
+	}
+	client.SetAutoRefreshCookies(true)
+	if err := client.AddToView(bilibili.VideoParam{Aid: 170001}); err != nil {
+		t.Fatal(err)
+	}
+	if server.HasSession(session.SESSDATA) {
+		t.Fatal("old session should be invalidated after auto refresh")
+	}
+}
@@ -0,0 +1,701 @@
+package bilibili
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const remuxTimescale = 1000 // 输出文件 mvhd 的 timescale，即以毫秒为单位
+
+// RemuxInput 是 Remux 的一个输入，即下载得到的一个 DASH 流（m4s 文件）
+type RemuxInput struct {
+	Reader      io.ReaderAt
+	Size        int64
+	SegmentBase *SegmentBase // 对应的 AudioOrVideo.SegmentBase，用于定位文件头和索引。为 nil 时从头解析文件
+}
+
+// MP4Metadata 是写入 MP4 文件的元数据
+type MP4Metadata struct {
+	Title   string    // 标题
+	Artist  string    // 作者
+	Comment string    // 简介
+	Date    time.Time // 发布时间
+	Cover   []byte    // 封面图片，JPEG 或 PNG 格式
+}
+
+// NewMP4Metadata 从 info 中取得标题、UP主、简介和发布时间。封面需要另外下载，例如：
+//
+//	resp, err := client.Resty().R().Get(info.Pic)
+//	meta.Cover = resp.Body()
+func NewMP4Metadata(info *VideoInfo) *MP4Metadata {
+	meta := &MP4Metadata{Title: info.Title, Artist: info.Owner.Name, Comment: info.Desc}
+	if info.Pubdate > 0 {
+		meta.Date = time.Unix(int64(info.Pubdate), 0)
+	}
+	return meta
+}
+
+// RemuxFiles 把 Downloader 下载的视频流和音频流合并为 outputPath，使用 Stream 中的 SegmentBase 定位文件头和索引。
+// audio.Path 为空时只有视频，meta 为 nil 时不写入元数据。
+func RemuxFiles(outputPath string, video, audio DownloadTrack, meta *MP4Metadata) (err error) {
+	var inputs []RemuxInput
+	for _, track := range []DownloadTrack{video, audio} {
+		if len(track.Path) == 0 {
+			continue
+		}
+		f, err := os.Open(track.Path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer func() { _ = f.Close() }()
+		info, err := f.Stat()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		inputs = append(inputs, RemuxInput{Reader: f, Size: info.Size(), SegmentBase: segmentBaseOf(track.Stream)})
+	}
+	if len(inputs) == 0 {
+		return errors.Wrap(ErrInvalidParam, "没有指定视频文件")
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = errors.WithStack(closeErr)
+		}
+		if err != nil {
+			_ = os.Remove(outputPath)
+		}
+	}()
+	return Remux(out, meta, inputs...)
+}
+
+// Remux 把若干个 DASH 流（通常是一个视频流和一个音频流）合并为一个 fragmented MP4 文件写入 w，不需要 ffmpeg。
+//
+// 每个输入的 moov 中的轨道会被放进同一个 moov 里，各个输入的 moof 和 mdat 按照解码时间交错排列，数据本身不会被修改。
+// meta 为 nil 时不写入元数据。
+func Remux(w io.Writer, meta *MP4Metadata, inputs ...RemuxInput) error {
+	if len(inputs) == 0 {
+		return errors.Wrap(ErrInvalidParam, "没有指定要合并的流")
+	}
+	tracks := make([]*remuxTrack, 0, len(inputs))
+	var fragments []*mp4Fragment
+	for i, input := range inputs {
+		t, err := loadRemuxTrack(input, uint32(i+1))
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, t)
+		fragments = append(fragments, t.fragments...)
+	}
+	sort.SliceStable(fragments, func(i, j int) bool {
+		a, b := fragments[i], fragments[j]
+		return a.time*uint64(b.track.timescale) < b.time*uint64(a.track.timescale)
+	})
+
+	ftyp := makeBox("ftyp", []byte("isom"), be32(0x200), []byte("isomiso6mp41"))
+	if _, err := w.Write(ftyp); err != nil {
+		return errors.WithStack(err)
+	}
+	moov, err := buildMoov(tracks, meta)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(moov); err != nil {
+		return errors.WithStack(err)
+	}
+	for i, f := range fragments {
+		moof, err := f.rewrite(uint32(i + 1))
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(moof); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err = io.Copy(w, io.NewSectionReader(f.track.input.Reader, f.mdat.offset, f.mdat.size)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// remuxTrack 是一个输入中的轨道
+type remuxTrack struct {
+	input          RemuxInput
+	id             uint32 // 输出文件中的 track_ID
+	trak           []byte // trak 盒子的内容，不包括盒子头
+	trex           []byte // trex 盒子的内容，不包括盒子头
+	movieTimescale uint32 // 输入文件 mvhd 的 timescale，edts 中的时长使用它
+	timescale      uint32 // mdhd 的 timescale，tfdt 中的解码时间使用它
+	duration       uint64 // 时长，单位为 remuxTimescale。从 sidx 得到，没有 sidx 时由最后一个分段的结束时间得到
+	fragments      []*mp4Fragment
+}
+
+// mp4Fragment 是一对 moof 和 mdat
+type mp4Fragment struct {
+	track *remuxTrack
+	moof  []byte // moof 盒子的内容，不包括盒子头
+	mdat  fileBox
+	time  uint64 // tfdt 中的解码时间
+}
+
+func loadRemuxTrack(input RemuxInput, id uint32) (*remuxTrack, error) {
+	t := &remuxTrack{input: input, id: id}
+	initStart, initEnd, mediaStart := int64(0), input.Size, int64(0)
+	if sb := input.SegmentBase; sb != nil && len(sb.Initialization) > 0 && len(sb.IndexRange) > 0 {
+		// Initialization 是 ftyp 和 moov 的范围，IndexRange 是 sidx 的范围，sidx 之后是 moof 和 mdat
+		var err error
+		if initStart, initEnd, err = parseByteRange(sb.Initialization); err != nil {
+			return nil, err
+		}
+		if mediaStart, _, err = parseByteRange(sb.IndexRange); err != nil {
+			return nil, err
+		}
+		if initEnd > input.Size || mediaStart > input.Size {
+			return nil, errors.Errorf("MP4 文件格式错误：SegmentBase 超出了文件大小 %d", input.Size)
+		}
+	}
+
+	initBoxes, err := scanBoxes(input.Reader, initStart, initEnd)
+	if err != nil {
+		return nil, err
+	}
+	moov := findFileBox(initBoxes, "moov")
+	if moov == nil {
+		return nil, errors.New("MP4 文件格式错误：没有找到 moov")
+	}
+	if mediaStart == 0 {
+		mediaStart = moov.offset + moov.size
+	}
+	if err = t.parseMoov(*moov); err != nil {
+		return nil, err
+	}
+
+	mediaBoxes, err := scanBoxes(input.Reader, mediaStart, input.Size)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(mediaBoxes); i++ {
+		b := mediaBoxes[i]
+		switch b.typ {
+		case "sidx":
+			if t.duration == 0 {
+				payload, err := readBoxPayload(input.Reader, b)
+				if err != nil {
+					return nil, err
+				}
+				t.duration = sidxDuration(payload)
+			}
+		case "moof":
+			if i+1 >= len(mediaBoxes) || mediaBoxes[i+1].typ != "mdat" {
+				return nil, errors.New("MP4 文件格式错误：moof 之后没有 mdat")
+			}
+			if b.headerSize != 8 {
+				return nil, errors.New("MP4 文件格式错误：不支持 64 位大小的 moof")
+			}
+			payload, err := readBoxPayload(input.Reader, b)
+			if err != nil {
+				return nil, err
+			}
+			f := &mp4Fragment{track: t, moof: payload, mdat: mediaBoxes[i+1]}
+			if f.time, err = fragmentTime(payload); err != nil {
+				return nil, err
+			}
+			t.fragments = append(t.fragments, f)
+			i++
+		}
+	}
+	if len(t.fragments) == 0 {
+		return nil, errors.New("MP4 文件格式错误：没有找到 moof，不是 DASH 流")
+	}
+	if t.duration == 0 && t.timescale > 0 {
+		var end uint64
+		for _, f := range t.fragments {
+			if e := f.time + fragmentDuration(f.moof, t.trex); e > end {
+				end = e
+			}
+		}
+		t.duration = end * remuxTimescale / uint64(t.timescale)
+	}
+	return t, nil
+}
+
+func (t *remuxTrack) parseMoov(moov fileBox) error {
+	payload, err := readBoxPayload(t.input.Reader, moov)
+	if err != nil {
+		return err
+	}
+	boxes, err := parseBoxes(payload)
+	if err != nil {
+		return err
+	}
+	for _, b := range boxes {
+		switch b.typ {
+		case "mvhd":
+			if t.movieTimescale, err = timescaleOf(b.payload); err != nil {
+				return err
+			}
+		case "trak":
+			if t.trak != nil {
+				return errors.New("MP4 文件格式错误：每个输入只能有一个轨道")
+			}
+			t.trak = append([]byte(nil), b.payload...)
+		case "mvex":
+			children, err := parseBoxes(b.payload)
+			if err != nil {
+				return err
+			}
+			if trex := findBox(children, "trex"); trex != nil && len(trex.payload) >= 8 {
+				t.trex = append([]byte(nil), trex.payload...)
+			}
+		}
+	}
+	if t.trak == nil || t.trex == nil {
+		return errors.New("MP4 文件格式错误：没有找到 trak 或 trex，不是 fragmented MP4")
+	}
+	mdia, err := findPath(t.trak, "mdia", "mdhd")
+	if err != nil {
+		return err
+	}
+	t.timescale, err = timescaleOf(mdia)
+	return err
+}
+
+// buildTrak 返回修改了 track_ID 和时长的 trak 盒子
+func (t *remuxTrack) buildTrak() ([]byte, error) {
+	trak := append([]byte(nil), t.trak...)
+	boxes, err := parseBoxes(trak)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range boxes {
+		switch b.typ {
+		case "tkhd":
+			p := b.payload
+			idOffset, durationOffset := 12, 20
+			if len(p) > 0 && p[0] == 1 {
+				idOffset, durationOffset = 20, 28
+			}
+			if len(p) < durationOffset+8 {
+				return nil, errors.New("MP4 文件格式错误：tkhd 不完整")
+			}
+			binary.BigEndian.PutUint32(p[idOffset:], t.id)
+			if p[0] == 1 {
+				binary.BigEndian.PutUint64(p[durationOffset:], t.duration)
+			} else {
+				binary.BigEndian.PutUint32(p[durationOffset:], uint32(t.duration))
+			}
+		case "edts":
+			// elst 中的时长使用 mvhd 的 timescale，需要换算为输出文件的 timescale
+			children, err := parseBoxes(b.payload)
+			if err != nil {
+				return nil, err
+			}
+			if elst := findBox(children, "elst"); elst != nil && t.movieTimescale > 0 {
+				scaleElst(elst.payload, t.movieTimescale)
+			}
+		}
+	}
+	return makeBox("trak", trak), nil
+}
+
+// rewrite 返回修改了序号和 track_ID 的 moof 盒子，盒子的大小不变，因此 trun 中的 data_offset 仍然正确
+func (f *mp4Fragment) rewrite(sequence uint32) ([]byte, error) {
+	moof := append([]byte(nil), f.moof...)
+	boxes, err := parseBoxes(moof)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range boxes {
+		switch b.typ {
+		case "mfhd":
+			if len(b.payload) < 8 {
+				return nil, errors.New("MP4 文件格式错误：mfhd 不完整")
+			}
+			binary.BigEndian.PutUint32(b.payload[4:], sequence)
+		case "traf":
+			children, err := parseBoxes(b.payload)
+			if err != nil {
+				return nil, err
+			}
+			tfhd := findBox(children, "tfhd")
+			if tfhd == nil || len(tfhd.payload) < 8 {
+				return nil, errors.New("MP4 文件格式错误：没有找到 tfhd")
+			}
+			if tfhd.payload[3]&1 != 0 {
+				return nil, errors.New("MP4 文件格式错误：不支持 tfhd 中的 base_data_offset")
+			}
+			binary.BigEndian.PutUint32(tfhd.payload[4:], f.track.id)
+		}
+	}
+	return makeBox("moof", moof), nil
+}
+
+func buildMoov(tracks []*remuxTrack, meta *MP4Metadata) ([]byte, error) {
+	var duration uint64
+	traks := make([][]byte, 0, len(tracks)+3)
+	trexes := make([][]byte, 0, len(tracks)+1)
+	for _, t := range tracks {
+		if t.duration > duration {
+			duration = t.duration
+		}
+		trak, err := t.buildTrak()
+		if err != nil {
+			return nil, err
+		}
+		traks = append(traks, trak)
+		trex := append([]byte(nil), t.trex...)
+		binary.BigEndian.PutUint32(trex[4:], t.id)
+		trexes = append(trexes, makeBox("trex", trex))
+	}
+	mvhd := fullBox("mvhd", 0, 0,
+		be32(0), be32(0), be32(remuxTimescale), be32(uint32(duration)),
+		be32(0x00010000), []byte{1, 0}, make([]byte, 10),
+		be32(0x00010000), be32(0), be32(0), be32(0), be32(0x00010000), be32(0), be32(0), be32(0), be32(0x40000000),
+		make([]byte, 24), be32(uint32(len(tracks)+1)),
+	)
+	mvex := makeBox("mvex", append([][]byte{fullBox("mehd", 0, 0, be32(uint32(duration)))}, trexes...)...)
+	children := append([][]byte{mvhd}, traks...)
+	children = append(children, mvex)
+	if udta := metadataBox(meta); udta != nil {
+		children = append(children, udta)
+	}
+	return makeBox("moov", children...), nil
+}
+
+// metadataBox 返回 iTunes 风格的 udta/meta/ilst 元数据
+func metadataBox(meta *MP4Metadata) []byte {
+	if meta == nil {
+		return nil
+	}
+	var items [][]byte
+	add := func(typ string, dataType uint32, value []byte) {
+		if len(value) > 0 {
+			items = append(items, makeBox(typ, makeBox("data", be32(dataType), be32(0), value)))
+		}
+	}
+	add("\xa9nam", 1, []byte(meta.Title))
+	add("\xa9ART", 1, []byte(meta.Artist))
+	add("\xa9cmt", 1, []byte(meta.Comment))
+	if !meta.Date.IsZero() {
+		add("\xa9day", 1, []byte(meta.Date.UTC().Format(time.RFC3339)))
+	}
+	coverType := uint32(13) // JPEG
+	if bytes.HasPrefix(meta.Cover, []byte("\x89PNG")) {
+		coverType = 14
+	}
+	add("covr", coverType, meta.Cover)
+	if len(items) == 0 {
+		return nil
+	}
+	hdlr := fullBox("hdlr", 0, 0, be32(0), []byte("mdir"), []byte("appl"), make([]byte, 8), []byte{0})
+	return makeBox("udta", fullBox("meta", 0, 0, hdlr, makeBox("ilst", items...)))
+}
+
+// fileBox 是文件中的一个盒子的位置
+type fileBox struct {
+	typ        string
+	offset     int64
+	size       int64
+	headerSize int64
+}
+
+// scanBoxes 读取 [start, end) 中连续的盒子的位置，不读取盒子的内容
+func scanBoxes(r io.ReaderAt, start, end int64) ([]fileBox, error) {
+	var boxes []fileBox
+	header := make([]byte, 16)
+	for offset := start; offset < end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		b := fileBox{typ: string(header[4:8]), offset: offset, size: int64(binary.BigEndian.Uint32(header)), headerSize: 8}
+		switch b.size {
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			b.size, b.headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		case 0:
+			b.size = end - offset
+		}
+		if b.size < b.headerSize || offset+b.size > end {
+			return nil, errors.Errorf("MP4 文件格式错误：%q 的大小不正确", b.typ)
+		}
+		boxes = append(boxes, b)
+		offset += b.size
+	}
+	return boxes, nil
+}
+
+func findFileBox(boxes []fileBox, typ string) *fileBox {
+	for i := range boxes {
+		if boxes[i].typ == typ {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+func readBoxPayload(r io.ReaderAt, b fileBox) ([]byte, error) {
+	payload := make([]byte, b.size-b.headerSize)
+	if _, err := r.ReadAt(payload, b.offset+b.headerSize); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return payload, nil
+}
+
+// mp4Box 是内存中的一个盒子，payload 与解析的数据共享内存，修改 payload 会修改原来的数据
+type mp4Box struct {
+	typ     string
+	payload []byte
+}
+
+// parseBoxes 解析 data 中连续的盒子
+func parseBoxes(data []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("MP4 文件格式错误：盒子不完整")
+		}
+		size, headerSize := uint64(binary.BigEndian.Uint32(data)), uint64(8)
+		typ := string(data[4:8])
+		switch size {
+		case 1:
+			if len(data) < 16 {
+				return nil, errors.New("MP4 文件格式错误：盒子不完整")
+			}
+			size, headerSize = binary.BigEndian.Uint64(data[8:]), 16
+		case 0:
+			size = uint64(len(data))
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return nil, errors.Errorf("MP4 文件格式错误：%q 的大小不正确", typ)
+		}
+		boxes = append(boxes, mp4Box{typ: typ, payload: data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []mp4Box, typ string) *mp4Box {
+	for i := range boxes {
+		if boxes[i].typ == typ {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// findPath 在 data 中按照 path 逐层查找盒子，返回最后一个盒子的内容
+func findPath(data []byte, path ...string) ([]byte, error) {
+	for _, typ := range path {
+		boxes, err := parseBoxes(data)
+		if err != nil {
+			return nil, err
+		}
+		b := findBox(boxes, typ)
+		if b == nil {
+			return nil, errors.Errorf("MP4 文件格式错误：没有找到 %s", strings.Join(path, "/"))
+		}
+		data = b.payload
+	}
+	return data, nil
+}
+
+func makeBox(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range payloads {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func fullBox(typ string, version byte, flags uint32, payloads ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return makeBox(typ, append([][]byte{header}, payloads...)...)
+}
+
+func be32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// timescaleOf 返回 mvhd 或 mdhd 中的 timescale
+func timescaleOf(payload []byte) (uint32, error) {
+	offset := 12
+	if len(payload) > 0 && payload[0] == 1 {
+		offset = 20
+	}
+	if len(payload) < offset+4 {
+		return 0, errors.New("MP4 文件格式错误：mvhd 或 mdhd 不完整")
+	}
+	return binary.BigEndian.Uint32(payload[offset:]), nil
+}
+
+// fragmentTime 返回 moof 中 tfdt 的解码时间
+func fragmentTime(moof []byte) (uint64, error) {
+	tfdt, err := findPath(moof, "traf", "tfdt")
+	if err != nil {
+		return 0, err
+	}
+	if len(tfdt) >= 12 && tfdt[0] == 1 {
+		return binary.BigEndian.Uint64(tfdt[4:]), nil
+	}
+	if len(tfdt) < 8 {
+		return 0, errors.New("MP4 文件格式错误：tfdt 不完整")
+	}
+	return uint64(binary.BigEndian.Uint32(tfdt[4:])), nil
+}
+
+// fragmentDuration 返回 moof 中全部样本的总时长，单位为 mdhd 的 timescale，无法解析时返回0。
+// 每个样本的时长依次取自 trun、tfhd 的 default_sample_duration 和 trex 的 default_sample_duration
+func fragmentDuration(moof, trex []byte) uint64 {
+	traf, err := findPath(moof, "traf")
+	if err != nil {
+		return 0
+	}
+	boxes, err := parseBoxes(traf)
+	if err != nil {
+		return 0
+	}
+	var defaultDuration uint32
+	if len(trex) >= 16 {
+		defaultDuration = binary.BigEndian.Uint32(trex[12:])
+	}
+	if tfhd := findBox(boxes, "tfhd"); tfhd != nil && len(tfhd.payload) >= 8 {
+		flags := binary.BigEndian.Uint32(tfhd.payload) & 0xffffff
+		offset := 8
+		if flags&0x01 != 0 { // base_data_offset
+			offset += 8
+		}
+		if flags&0x02 != 0 { // sample_description_index
+			offset += 4
+		}
+		if flags&0x08 != 0 && len(tfhd.payload) >= offset+4 {
+			defaultDuration = binary.BigEndian.Uint32(tfhd.payload[offset:])
+		}
+	}
+	var duration uint64
+	for _, b := range boxes {
+		if b.typ != "trun" || len(b.payload) < 8 {
+			continue
+		}
+		flags := binary.BigEndian.Uint32(b.payload) & 0xffffff
+		count := uint64(binary.BigEndian.Uint32(b.payload[4:]))
+		if flags&0x100 == 0 {
+			duration += count * uint64(defaultDuration)
+			continue
+		}
+		offset := 8
+		if flags&0x01 != 0 { // data_offset
+			offset += 4
+		}
+		if flags&0x04 != 0 { // first_sample_flags
+			offset += 4
+		}
+		sampleSize := 4
+		for _, flag := range []uint32{0x200, 0x400, 0x800} { // sample_size、sample_flags、sample_composition_time_offset
+			if flags&flag != 0 {
+				sampleSize += 4
+			}
+		}
+		for i := uint64(0); i < count && len(b.payload) >= offset+4; i++ {
+			duration += uint64(binary.BigEndian.Uint32(b.payload[offset:]))
+			offset += sampleSize
+		}
+	}
+	return duration
+}
+
+// sidxDuration 返回 sidx 中全部分段的总时长，单位为 remuxTimescale，无法解析时返回0
+func sidxDuration(sidx []byte) uint64 {
+	if len(sidx) < 12 {
+		return 0
+	}
+	timescale := uint64(binary.BigEndian.Uint32(sidx[8:]))
+	countOffset := 22
+	if sidx[0] == 1 {
+		countOffset = 30
+	}
+	if timescale == 0 || len(sidx) < countOffset+2 {
+		return 0
+	}
+	count := int(binary.BigEndian.Uint16(sidx[countOffset:]))
+	entries := sidx[countOffset+2:]
+	if len(entries) < count*12 {
+		return 0
+	}
+	var duration uint64
+	for i := 0; i < count; i++ {
+		duration += uint64(binary.BigEndian.Uint32(entries[i*12+4:]))
+	}
+	return duration * remuxTimescale / timescale
+}
+
+// scaleElst 把 elst 中的 segment_duration 从 timescale 换算为 remuxTimescale
+func scaleElst(elst []byte, timescale uint32) {
+	if len(elst) < 8 {
+		return
+	}
+	count := int(binary.BigEndian.Uint32(elst[4:]))
+	entrySize := 12
+	if elst[0] == 1 {
+		entrySize = 20
+	}
+	entries := elst[8:]
+	for i := 0; i < count && (i+1)*entrySize <= len(entries); i++ {
+		e := entries[i*entrySize:]
+		if elst[0] == 1 {
+			binary.BigEndian.PutUint64(e, binary.BigEndian.Uint64(e)*remuxTimescale/uint64(timescale))
+		} else {
+			binary.BigEndian.PutUint32(e, uint32(uint64(binary.BigEndian.Uint32(e))*remuxTimescale/uint64(timescale)))
+		}
+	}
+}
+
+// parseByteRange 解析 SegmentBase 中 "0-821" 格式的范围，返回 [start, end)
+func parseByteRange(s string) (start, end int64, err error) {
+	first, last, ok := strings.Cut(s, "-")
+	if ok {
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err == nil {
+			end, err = strconv.ParseInt(last, 10, 64)
+		}
+	}
+	if !ok || err != nil || start < 0 || end < start {
+		return 0, 0, errors.Errorf("SegmentBase 的范围格式错误: %s", s)
+	}
+	return start, end + 1, nil
+}
+
+// segmentBaseOf 返回 stream 中不为空的 SegmentBase
+func segmentBaseOf(stream *AudioOrVideo) *SegmentBase {
+	if stream == nil {
+		return nil
+	}
+	if len(stream.SegmentBase.Initialization) > 0 {
+		return &stream.SegmentBase
+	}
+	if len(stream.Segmentbase.Initialization) > 0 {
+		return &stream.Segmentbase
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package bilibili
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeDashStream 构造一个最小的 DASH 流：ftyp、moov、sidx，以及每个 tfdt 对应的一对 moof 和 mdat。
+// withSidx 为 false 时不写入 sidx，改为在 trun 中写入两个样本的时长。返回文件内容和对应的 SegmentBase
+func fakeDashStream(trackId, timescale, fragmentDuration uint32, tfdts []uint32, sample string, withSidx bool) ([]byte, SegmentBase) {
+	tkhd := fullBox("tkhd", 0, 3, be32(0), be32(0), be32(trackId), be32(0), be32(0), make([]byte, 60))
+	elst := fullBox("elst", 0, 0, be32(1), be32(2000), be32(0), be32(0x00010000)) // mvhd 的 timescale 为 1000 时 2 秒
+	mdhd := fullBox("mdhd", 0, 0, be32(0), be32(0), be32(timescale), be32(0), be32(0))
+	trak := makeBox("trak", tkhd, makeBox("edts", elst), makeBox("mdia", mdhd))
+	mvhd := fullBox("mvhd", 0, 0, be32(0), be32(0), be32(1000), be32(0), make([]byte, 80))
+	trex := fullBox("trex", 0, 0, be32(trackId), be32(1), be32(0), be32(0), be32(0))
+	init := append(makeBox("ftyp", []byte("iso5"), be32(1), []byte("avc1iso5dashmp41")),
+		makeBox("moov", mvhd, trak, makeBox("mvex", trex))...)
+
+	entries := []byte{}
+	for range tfdts {
+		entries = append(entries, be32(0)...)
+		entries = append(entries, be32(fragmentDuration)...)
+		entries = append(entries, be32(0x90000000)...)
+	}
+	sidx := fullBox("sidx", 0, 0, be32(1), be32(timescale), be32(0), be32(0), []byte{0, 0, 0, byte(len(tfdts))}, entries)
+
+	if !withSidx {
+		sidx = nil
+	}
+
+	file := append(append([]byte(nil), init...), sidx...)
+	for i, tfdt := range tfdts {
+		data := []byte(sample + strconv.Itoa(i))
+		traf := makeBox("traf",
+			fullBox("tfhd", 0, 0x020000, be32(trackId)),
+			fullBox("tfdt", 0, 0, be32(tfdt)),
+		)
+		if !withSidx {
+			// data_offset、sample_duration、sample_size
+			trun := fullBox("trun", 0, 0x301, be32(2), be32(0),
+				be32(fragmentDuration/2), be32(uint32(len(data)/2)),
+				be32(fragmentDuration-fragmentDuration/2), be32(uint32(len(data)-len(data)/2)))
+			traf = append(traf, trun...)
+			binary.BigEndian.PutUint32(traf, uint32(len(traf)))
+		}
+		file = append(file, makeBox("moof", fullBox("mfhd", 0, 0, be32(uint32(i+1))), traf)...)
+		file = append(file, makeBox("mdat", data)...)
+	}
+	if !withSidx {
+		return file, SegmentBase{}
+	}
+	return file, SegmentBase{
+		Initialization: "0-" + strconv.Itoa(len(init)-1),
+		IndexRange:     strconv.Itoa(len(init)) + "-" + strconv.Itoa(len(init)+len(sidx)-1),
+	}
+}
+
+func TestRemux(t *testing.T) {
+	video, videoBase := fakeDashStream(1, 16000, 16000, []uint32{0, 16000}, "video", true)
+	audio, audioBase := fakeDashStream(1, 48000, 48000, []uint32{0, 48000}, "audio", true)
+	meta := NewMP4Metadata(&VideoInfo{Title: "标题", Desc: "简介", Pubdate: 1700000000, Owner: Owner{Name: "UP主"}})
+	meta.Cover = []byte("\x89PNG cover")
+
+	for _, useSegmentBase := range []bool{true, false} {
+		videoInput := RemuxInput{Reader: bytes.NewReader(video), Size: int64(len(video))}
+		audioInput := RemuxInput{Reader: bytes.NewReader(audio), Size: int64(len(audio))}
+		if useSegmentBase {
+			videoInput.SegmentBase, audioInput.SegmentBase = &videoBase, &audioBase
+		}
+		var out bytes.Buffer
+		if err := Remux(&out, meta, videoInput, audioInput); err != nil {
+			t.Fatal(err)
+		}
+		checkRemuxOutput(t, out.Bytes())
+	}
+}
+
+func TestRemuxWithoutSidx(t *testing.T) {
+	video, _ := fakeDashStream(1, 16000, 16000, []uint32{0, 16000}, "video", false)
+	audio, _ := fakeDashStream(1, 48000, 48000, []uint32{0, 48000}, "audio", false)
+	meta := NewMP4Metadata(&VideoInfo{Title: "标题", Desc: "简介", Pubdate: 1700000000, Owner: Owner{Name: "UP主"}})
+	meta.Cover = []byte("\x89PNG cover")
+
+	// 时长由最后一个分段的 tfdt 加上 trun 中样本的时长得到
+	var out bytes.Buffer
+	err := Remux(&out, meta, RemuxInput{Reader: bytes.NewReader(video), Size: int64(len(video))},
+		RemuxInput{Reader: bytes.NewReader(audio), Size: int64(len(audio))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRemuxOutput(t, out.Bytes())
+
+	// trun 中没有样本时长时，使用 tfhd 或 trex 中的默认时长
+	trun := fullBox("trun", 0, 0, be32(10))
+	trex := fullBox("trex", 0, 0, be32(1), be32(1), be32(30), be32(0), be32(0))[8:]
+	for _, c := range []struct {
+		tfhd     []byte
+		expected uint64
+	}{
+		{fullBox("tfhd", 0, 0x020008, be32(1), be32(20)), 200},
+		{fullBox("tfhd", 0, 0x02000a, be32(1), be32(1), be32(40)), 400},
+		{fullBox("tfhd", 0, 0x020000, be32(1)), 300},
+	} {
+		if d := fragmentDuration(makeBox("traf", c.tfhd, trun), trex); d != c.expected {
+			t.Errorf("fragment duration: %d, expected %d", d, c.expected)
+		}
+	}
+}
+
+func checkRemuxOutput(t *testing.T, data []byte) {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, b := range boxes {
+		types = append(types, b.typ)
+	}
+	expectedTypes := []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat", "moof", "mdat", "moof", "mdat"}
+	if len(types) != len(expectedTypes) {
+		t.Fatalf("boxes: %v", types)
+	}
+	for i := range types {
+		if types[i] != expectedTypes[i] {
+			t.Fatalf("boxes: %v", types)
+		}
+	}
+
+	moov, _ := parseBoxes(boxes[1].payload)
+	mvhd := findBox(moov, "mvhd")
+	if timescale, _ := timescaleOf(mvhd.payload); timescale != remuxTimescale {
+		t.Errorf("mvhd timescale: %d", timescale)
+	}
+	if duration := binary.BigEndian.Uint32(mvhd.payload[16:]); duration != 2000 {
+		t.Errorf("mvhd duration: %d", duration)
+	}
+	var trackIds []uint32
+	for _, b := range moov {
+		if b.typ == "trak" {
+			tkhd, err := findPath(b.payload, "tkhd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			trackIds = append(trackIds, binary.BigEndian.Uint32(tkhd[12:]))
+		}
+	}
+	if len(trackIds) != 2 || trackIds[0] != 1 || trackIds[1] != 2 {
+		t.Errorf("track ids: %v", trackIds)
+	}
+	mvex, _ := parseBoxes(findBox(moov, "mvex").payload)
+	var trexIds []uint32
+	for _, b := range mvex {
+		if b.typ == "trex" {
+			trexIds = append(trexIds, binary.BigEndian.Uint32(b.payload[4:]))
+		}
+	}
+	if len(trexIds) != 2 || trexIds[0] != 1 || trexIds[1] != 2 {
+		t.Errorf("trex ids: %v", trexIds)
+	}
+
+	ilst, err := findPath(findBox(moov, "udta").payload, "meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ilst, err = findPath(ilst[4:], "ilst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := parseBoxes(ilst)
+	values := map[string]string{}
+	for _, item := range items {
+		data, err := findPath(item.payload, "data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[item.typ] = string(data[8:])
+		if item.typ == "covr" && binary.BigEndian.Uint32(data) != 14 {
+			t.Errorf("cover type: %d", binary.BigEndian.Uint32(data))
+		}
+	}
+	expectedValues := map[string]string{
+		"\xa9nam": "标题",
+		"\xa9ART": "UP主",
+		"\xa9cmt": "简介",
+		"\xa9day": time.Unix(1700000000, 0).UTC().Format(time.RFC3339),
+		"covr":    "\x89PNG cover",
+	}
+	for k, v := range expectedValues {
+		if values[k] != v {
+			t.Errorf("metadata %q: %q", k, values[k])
+		}
+	}
+
+	// 按照解码时间交错，序号重新编号，track_ID 改为输出文件中的编号
+	expectedMdats := []string{"video0", "audio0", "video1", "audio1"}
+	expectedTracks := []uint32{1, 2, 1, 2}
+	for i := 0; i < 4; i++ {
+		moof := boxes[2+i*2].payload
+		mfhd, _ := findPath(moof, "mfhd")
+		if seq := binary.BigEndian.Uint32(mfhd[4:]); seq != uint32(i+1) {
+			t.Errorf("fragment %d sequence: %d", i, seq)
+		}
+		tfhd, _ := findPath(moof, "traf", "tfhd")
+		if id := binary.BigEndian.Uint32(tfhd[4:]); id != expectedTracks[i] {
+			t.Errorf("fragment %d track id: %d", i, id)
+		}
+		if mdat := string(boxes[3+i*2].payload); mdat != expectedMdats[i] {
+			t.Errorf("fragment %d mdat: %q", i, mdat)
+		}
+	}
+}
+
+func TestRemuxFiles(t *testing.T) {
+	dir := t.TempDir()
+	video, videoBase := fakeDashStream(1, 1000, 1000, []uint32{0, 1000}, "video", true)
+	videoPath := filepath.Join(dir, "video.m4s")
+	if err := os.WriteFile(videoPath, video, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.mp4")
+	stream := &AudioOrVideo{Segmentbase: videoBase}
+	if err := RemuxFiles(output, DownloadTrack{Stream: stream, Path: videoPath}, DownloadTrack{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boxes) != 6 || boxes[1].typ != "moov" {
+		t.Fatalf("boxes: %d", len(boxes))
+	}
+	moov, _ := parseBoxes(boxes[1].payload)
+	if findBox(moov, "udta") != nil {
+		t.Error("unexpected udta without metadata")
+	}
+
+	// 不是 DASH 流时返回错误，并且不留下输出文件
+	if err = os.WriteFile(videoPath, video[:len(video)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = RemuxFiles(output, DownloadTrack{Path: videoPath}, DownloadTrack{}, nil); err == nil {
+		t.Error("expected error for truncated file")
+	}
+	if _, err = os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output should be removed: %v", err)
+	}
+}
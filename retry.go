@@ -0,0 +1,149 @@
+package bilibili
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy 重试策略，使用 Client.SetRetryPolicy 设置。默认不重试。
+//
+// 第 n 次重试前等待 BaseDelay * 2^(n-1)，最长不超过 MaxDelay，再随机减去其中 Jitter 比例的时间。
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数，包含第一次请求。小于等于1表示不重试
+	BaseDelay   time.Duration // 第一次重试前的等待时间
+	MaxDelay    time.Duration // 最长等待时间。为0表示不限制
+	Jitter      float64       // 随机抖动比例，取值范围 [0, 1]
+
+	// Retryable 判断一个错误是否可以重试，为 nil 时使用 IsRetryable。
+	// 对于非幂等的请求（除 GET、HEAD、OPTIONS 以外的请求），只有 IsRejected 的错误才会重试，以免重复操作（例如重复投币）。
+	Retryable func(err error) bool
+
+	// OnRetry 在每次重试等待之前调用，可以用于记录日志或统计
+	OnRetry func(event RetryEvent)
+}
+
+// RetryEvent 一次重试的信息
+type RetryEvent struct {
+	Method  string        // 请求方法
+	Url     string        // 请求地址
+	Attempt int           // 即将进行第几次尝试，从2开始
+	Delay   time.Duration // 本次重试前的等待时间
+	Err     error         // 上一次尝试的错误
+}
+
+// DefaultRetryPolicy 返回一个推荐的重试策略：最多请求4次，等待时间从500ms开始翻倍，最长10s
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// SetRetryPolicy 设置重试策略，传入 nil 表示不重试
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) *Client {
+	c.retryPolicy = policy
+	return c
+}
+
+// IsRejected 判断错误是否表示请求被B站风控拦截或限流。这类请求并没有被B站处理，因此总是可以安全地重试。
+//
+// 包括：HTTP 412、HTTP 429、错误码 -412（请求被拦截）、-799（请求过于频繁）、-352（风控校验失败）
+func IsRejected(err error) bool {
+	return IsRiskControl(err) || IsRateLimited(err)
+}
+
+// IsRetryable 判断错误是否是暂时性的，重试可能会成功。
+//
+// 除 IsRejected 的情况外，还包括 HTTP 5xx 和网络超时（包括 http.Client.Timeout 导致的超时）。
+// 未登录（-101）、无权限（-403）、不存在（-404）等错误以及 context 的取消都不会重试。
+// 调用者的 context 超时后也不会再重试，这一点由 Client 判断，而不是根据错误的类型。
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsRejected(err) {
+		return true
+	}
+	var se StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// delay 计算第 attempt 次重试前需要等待的时间，attempt 从1开始
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// withRetry 按照 c 的重试策略执行 do，每一次执行前都会经过限流器。执行的次数记录在 call.Attempts 中
+func (c *Client) withRetry(call *Call, do func() error) error {
+	method, url := call.Method, call.Url
+	try := func() error {
+		if err := c.throttle(url); err != nil {
+			return err
+		}
+		call.Attempts++
+		call.Proxy = ""
+		err := do()
+		c.reportProxy(call, err)
+		return err
+	}
+	p := c.retryPolicy
+	if p == nil || p.MaxAttempts <= 1 {
+		return try()
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	ctx := c.Context()
+	for attempt := 1; ; attempt++ {
+		err := try()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		if !isIdempotent(method) && !IsRejected(err) {
+			return err
+		}
+		delay := p.delay(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(RetryEvent{Method: method, Url: url, Attempt: attempt + 1, Delay: delay, Err: err})
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.WithStack(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package bilibili_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+		rejected  bool
+	}{
+		{errors.WithStack(bilibili.StatusError{StatusCode: 412}), true, true},
+		{errors.WithStack(bilibili.StatusError{StatusCode: 502}), true, false},
+		{errors.WithStack(bilibili.StatusError{StatusCode: 404}), false, false},
+		{errors.WithStack(bilibili.Error{Code: -799, Message: "请求过于频繁，请稍后再试"}), true, true},
+		{errors.WithStack(bilibili.Error{Code: -352, Message: "风控校验失败"}), true, true},
+		{errors.WithStack(bilibili.Error{Code: -101, Message: "账号未登录"}), false, false},
+		{errors.WithStack(bilibili.Error{Code: -404, Message: "啥都木有"}), false, false},
+		{errors.WithStack(context.DeadlineExceeded), true, false},
+		{errors.WithStack(context.Canceled), false, false},
+		{nil, false, false},
+	}
+	for _, c := range cases {
+		if bilibili.IsRetryable(c.err) != c.retryable || bilibili.IsRejected(c.err) != c.rejected {
+			t.Fatal("classification not correct: ", c.err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var mu sync.Mutex
+	count := 0
+	handler := func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		switch {
+		case count == 1:
+			return nil, bilibili.StatusError{StatusCode: http.StatusPreconditionFailed}
+		case count == 2:
+			return nil, bilibili.Error{Code: -799, Message: "请求过于频繁，请稍后再试"}
+		case r.Method == http.MethodPost:
+			return nil, bilibili.StatusError{StatusCode: http.StatusBadGateway}
+		default:
+			return bilibili.ZoneLocation{Addr: "127.0.0.1"}, nil
+		}
+	}
+	setCount := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		count = n
+	}
+	getCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return count
+	}
+	server.Handle("/x/web-interface/zone", handler)
+	server.Handle("/x/v2/history/toview/add", handler)
+
+	var events []bilibili.RetryEvent
+	policy := &bilibili.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		OnRetry: func(event bilibili.RetryEvent) {
+			events = append(events, event)
+		},
+	}
+	c := server.NewClient().SetRetryPolicy(policy)
+	c.SetCookies(server.NewSession(2).Cookies())
+	zone, err := c.GetZoneLocation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Addr != "127.0.0.1" || getCount() != 3 || len(events) != 2 || events[1].Attempt != 3 {
+		t.Fatal("retry not correct ", getCount(), events)
+	}
+
+	// 非幂等请求在 5xx 时不应重试
+	setCount(2)
+	events = nil
+	err = c.AddToView(bilibili.VideoParam{Aid: 170001})
+	var se bilibili.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway || getCount() != 3 || len(events) != 0 {
+		t.Fatal("POST should not be retried on 5xx ", err, getCount())
+	}
+
+	// 达到最大次数后返回最后一次的错误
+	setCount(0)
+	events = nil
+	policy.MaxAttempts = 2
+	_, err = c.GetZoneLocation()
+	var e bilibili.Error
+	if !errors.As(err, &e) || e.Code != -799 || getCount() != 2 {
+		t.Fatal("expected error code -799, got ", err)
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const zonePath = "/x/web-interface/zone"
+	var mu sync.Mutex
+	slow := 1
+	server.Handle(zonePath, func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		mu.Lock()
+		wait := slow > 0
+		slow--
+		mu.Unlock()
+		if wait {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		return bilibili.ZoneLocation{Addr: "127.0.0.1"}, nil
+	})
+	c := server.NewClient()
+	c.Resty().SetTimeout(50 * time.Millisecond)
+
+	// http.Client.Timeout 导致的超时可以重试
+	_, err := c.GetZoneLocation()
+	if !bilibili.IsRetryable(err) {
+		t.Fatal("client timeout should be retryable ", err)
+	}
+	mu.Lock()
+	slow = 1
+	mu.Unlock()
+	c.SetRetryPolicy(&bilibili.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	zone, err := c.GetZoneLocation()
+	if err != nil || zone.Addr != "127.0.0.1" || server.RequestCount(zonePath) != 3 {
+		t.Fatal("timed out request should be retried ", err, server.RequestCount(zonePath))
+	}
+
+	// 调用者的 context 超时后不再重试
+	mu.Lock()
+	slow = 2
+	mu.Unlock()
+	c.Resty().SetTimeout(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = c.WithContext(ctx).GetZoneLocation(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected context.DeadlineExceeded, got ", err)
+	}
+	if count := server.RequestCount(zonePath); count != 4 {
+		t.Fatal("request should not be retried after the context is done ", count)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &bilibili.RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if d := p.Delay(attempt + 1); d != expected*time.Millisecond {
+			t.Fatalf("delay(%d) = %s, expected %s", attempt+1, d, expected*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatal("jitter delay out of range ", d)
+		}
+	}
+}
+
+func TestRetryUpload(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	var contents []string
+	server.Handle("/x/dynamic/feed/draw/upload_bfs", func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		file, _, err := r.FormFile("file_up")
+		if err != nil {
+			return nil, err
+		}
+		b, _ := io.ReadAll(file)
+		contents = append(contents, string(b))
+		if len(contents) == 1 {
+			return nil, bilibili.Error{Code: -412, Message: "请求被拦截"}
+		}
+		return map[string]any{"image_url": "https://i0.hdslb.com/bfs/new_dyn/a.png", "image_width": 1, "image_height": 1}, nil
+	})
+	c := server.NewClient().SetRetryPolicy(&bilibili.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	c.SetCookies(server.NewSession(2).Cookies())
+
+	url, _, err := c.UploadDynamicBfs("a.png", strings.NewReader("png"), "daily")
+	if err != nil || url == "" {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || contents[0] != "png" || contents[1] != "png" {
+		t.Fatal("retried upload should send the same file ", contents)
+	}
+}
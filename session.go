@@ -0,0 +1,221 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// Session 是需要持久化的登录状态
+type Session struct {
+	Cookies      []*http.Cookie `json:"cookies"`       // 登录后的cookies
+	RefreshToken string         `json:"refresh_token"` // 登录时返回的refresh_token，刷新cookies时需要
+	UpdatedAt    time.Time      `json:"updated_at"`    // 保存的时间
+}
+
+// SessionStore 储存登录状态。使用 Client.SetSessionStore 设置后，Client 会在收到新的cookies或者登录成功时自动保存。
+//
+// 实现需要是并发安全的。
+type SessionStore interface {
+	// Load 读取登录状态，没有保存过时返回 nil, nil
+	Load() (*Session, error)
+	// Save 保存登录状态
+	Save(session *Session) error
+}
+
+// MemorySessionStore 把登录状态保存在内存中，一般用于测试，或者在多个 Client 之间传递登录状态
+type MemorySessionStore struct {
+	mu      sync.Mutex
+	session *Session
+}
+
+// NewMemorySessionStore 返回一个空的 MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{}
+}
+
+func (s *MemorySessionStore) Load() (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.session.clone(), nil
+}
+
+func (s *MemorySessionStore) Save(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.session = session.clone()
+	return nil
+}
+
+// FileSessionStore 把登录状态以JSON格式保存在文件中。写入时先写临时文件再重命名，不会因为中途退出而损坏已有的文件。
+type FileSessionStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSessionStore 返回一个保存在 path 的 FileSessionStore，文件不存在时会在第一次保存时创建
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{path: path}
+}
+
+func (s *FileSessionStore) Load() (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var session Session
+	if err = json.Unmarshal(data, &session); err != nil {
+		return nil, errors.Wrap(err, "解析登录状态失败")
+	}
+	return &session, nil
+}
+
+func (s *FileSessionStore) Save(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmp := f.Name()
+	defer func() { _ = os.Remove(tmp) }()
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if err = f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	// cookies 相当于登录凭证，只允许当前用户读写
+	if err = os.Chmod(tmp, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, s.path))
+}
+
+func (s *Session) clone() *Session {
+	if s == nil {
+		return nil
+	}
+	s2 := *s
+	s2.Cookies = make([]*http.Cookie, 0, len(s.Cookies))
+	for _, cookie := range s.Cookies {
+		cookie2 := *cookie
+		s2.Cookies = append(s2.Cookies, &cookie2)
+	}
+	return &s2
+}
+
+// sessionState 是 Client 的登录状态，被 WithContext 返回的浅拷贝共享
+type sessionState struct {
+	mu           sync.Mutex
+	store        SessionStore
+	refreshToken string
+	autoRefresh  bool
+	refreshGroup singleflight.Group
+}
+
+// NewWithSessionStore 返回一个默认的 bilibili.Client，并从 store 中恢复登录状态
+func NewWithSessionStore(store SessionStore) (*Client, error) {
+	c := New()
+	if err := c.SetSessionStore(store); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetSessionStore 设置登录状态的储存，并立即从中恢复cookies和refresh_token。传入 nil 表示不再自动保存。
+//
+// 设置后，每当请求的响应中带有 Set-Cookie，或者登录成功时，都会自动保存当前的登录状态。
+func (c *Client) SetSessionStore(store SessionStore) error {
+	c.session.mu.Lock()
+	c.session.store = store
+	c.session.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+	session, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if session != nil {
+		c.SetCookies(session.Cookies)
+		c.session.mu.Lock()
+		c.session.refreshToken = session.RefreshToken
+		c.session.mu.Unlock()
+	}
+	return nil
+}
+
+// RefreshToken 获取登录时返回的refresh_token
+func (c *Client) RefreshToken() string {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	return c.session.refreshToken
+}
+
+// SetRefreshToken 设置refresh_token，如果设置了 SessionStore 会立即保存
+func (c *Client) SetRefreshToken(refreshToken string) error {
+	c.session.mu.Lock()
+	c.session.refreshToken = refreshToken
+	c.session.mu.Unlock()
+	return c.SaveSession()
+}
+
+// SaveSession 立即保存当前的登录状态，没有设置 SessionStore 时什么也不做
+func (c *Client) SaveSession() error {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	if c.session.store == nil {
+		return nil
+	}
+	session := &Session{
+		Cookies:      c.GetCookies(),
+		RefreshToken: c.session.refreshToken,
+		UpdatedAt:    time.Now(),
+	}
+	return c.session.store.Save(session)
+}
+
+// updateCookies 保存请求 rawUrl 得到的响应中的cookies。请求本身已经成功，保存登录状态失败不影响请求的结果，所以忽略错误
+func (c *Client) updateCookies(rawUrl string, cookies []*http.Cookie) {
+	if len(cookies) == 0 {
+		return
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	c.cookies.SetCookies(u, cookies)
+	_ = c.SaveSession()
+}
+
+// loginSucceeded 在登录成功后保存refresh_token和cookies
+func (c *Client) loginSucceeded(refreshToken string) error {
+	if len(refreshToken) == 0 {
+		return c.SaveSession()
+	}
+	return c.SetRefreshToken(refreshToken)
+}
@@ -0,0 +1,114 @@
+package bilibili_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func cookieValue(c *bilibili.Client, name string) string {
+	for _, cookie := range c.GetCookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func TestSessionStore(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+
+	store := bilibili.NewFileSessionStore(filepath.Join(t.TempDir(), "session.json"))
+	c, err := bilibili.NewWithSessionStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Configure(c)
+	qrCode, err := c.GetQRCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ConfirmQRCode(qrCode.QrcodeKey)
+	result, err := c.LoginWithQRCode(bilibili.LoginWithQRCodeParam{QrcodeKey: qrCode.QrcodeKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session == nil || session.RefreshToken != result.RefreshToken || len(session.Cookies) != 3 {
+		t.Fatal("session not saved after login ", session)
+	}
+
+	c2, err := bilibili.NewWithSessionStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.RefreshToken() != result.RefreshToken || len(cookieValue(c2, "bili_jct")) == 0 || cookieValue(c2, "bili_jct") != cookieValue(c, "bili_jct") {
+		t.Fatal("session not restored ", c2.GetCookiesString())
+	}
+
+	memory := bilibili.NewMemorySessionStore()
+	if session, err = memory.Load(); session != nil || err != nil {
+		t.Fatal("empty store should return nil, nil")
+	}
+	if err = c2.SetSessionStore(memory); err != nil {
+		t.Fatal(err)
+	}
+	if err = c2.SetRefreshToken("token2"); err != nil {
+		t.Fatal(err)
+	}
+	if session, _ = memory.Load(); session == nil || session.RefreshToken != "token2" || len(session.Cookies) != 3 {
+		t.Fatal("SetRefreshToken should save the session ", session)
+	}
+}
+
+func TestLoginNotSucceeded(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HandleFixture("/x/passport-login/web/key", map[string]any{
+		"hash": "0123456789abcdef",
+		"key":  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	})
+	server.HandleFixture("/x/passport-login/web/login", map[string]any{"status": 2, "message": "需要验证手机号", "url": "https://passport.bilibili.com/account/mobile/security/managephone/phone/verify"})
+	server.Handle("/x/passport-login/web/login/sms", func(http.ResponseWriter, *http.Request) (any, error) {
+		return nil, nil
+	})
+
+	store := bilibili.NewMemorySessionStore()
+	c := server.NewClient()
+	if err = c.SetSessionStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	// 还需要验证时不是登录成功，不保存登录状态
+	result, err := c.LoginWithPassword(bilibili.LoginWithPasswordParam{Username: "user", Password: "password"})
+	if err != nil || result == nil || result.Status != 2 {
+		t.Fatal("LoginWithPassword result not correct ", result, err)
+	}
+	// data 为 null 时不应 panic
+	if smsResult, err := c.LoginWithSMS(bilibili.LoginWithSMSParam{Tel: 1, Code: 123456}); err != nil || smsResult != nil {
+		t.Fatal("LoginWithSMS result not correct ", smsResult, err)
+	}
+	if session, _ := store.Load(); session != nil {
+		t.Fatal("session should not be saved ", session)
+	}
+}
@@ -0,0 +1,331 @@
+package bilibili
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Quality 是视频清晰度标识，即 GetVideoStreamParam 的 Qn 和 AudioOrVideo 的 Id
+type Quality int
+
+const (
+	Quality240P        Quality = 6   // 240P 极速，仅 MP4 格式
+	Quality360P        Quality = 16  // 360P 流畅
+	Quality480P        Quality = 32  // 480P 清晰
+	Quality720P        Quality = 64  // 720P 高清
+	Quality720P60      Quality = 74  // 720P60 高帧率
+	Quality1080P       Quality = 80  // 1080P 高清
+	Quality1080PPlus   Quality = 112 // 1080P+ 高码率，需要大会员
+	Quality1080P60     Quality = 116 // 1080P60 高帧率，需要大会员
+	Quality4K          Quality = 120 // 4K 超清，需要大会员和 Fnval4K
+	QualityHDR         Quality = 125 // HDR 真彩色，需要大会员和 FnvalHDR
+	QualityDolbyVision Quality = 126 // 杜比视界，需要大会员和 FnvalDolbyVision
+	Quality8K          Quality = 127 // 8K 超高清，需要大会员和 Fnval8K
+)
+
+func (q Quality) String() string {
+	switch q {
+	case Quality240P:
+		return "240P 极速"
+	case Quality360P:
+		return "360P 流畅"
+	case Quality480P:
+		return "480P 清晰"
+	case Quality720P:
+		return "720P 高清"
+	case Quality720P60:
+		return "720P60 高帧率"
+	case Quality1080P:
+		return "1080P 高清"
+	case Quality1080PPlus:
+		return "1080P+ 高码率"
+	case Quality1080P60:
+		return "1080P60 高帧率"
+	case Quality4K:
+		return "4K 超清"
+	case QualityHDR:
+		return "HDR 真彩色"
+	case QualityDolbyVision:
+		return "杜比视界"
+	case Quality8K:
+		return "8K 超高清"
+	default:
+		return "Quality(" + strconv.Itoa(int(q)) + ")"
+	}
+}
+
+// Height 返回清晰度对应的视频高度，未知的清晰度返回0。HDR 和杜比视界可能是各种分辨率，也返回0
+func (q Quality) Height() int {
+	switch {
+	case q == Quality8K:
+		return 4320
+	case q == QualityHDR || q == QualityDolbyVision:
+		return 0
+	case q >= Quality4K:
+		return 2160
+	case q >= Quality1080P:
+		return 1080
+	case q >= Quality720P:
+		return 720
+	case q >= Quality480P:
+		return 480
+	case q >= Quality360P:
+		return 360
+	case q >= Quality240P:
+		return 240
+	default:
+		return 0
+	}
+}
+
+// AudioQuality 是音频流的音质代码，即音频流的 AudioOrVideo.Id
+type AudioQuality int
+
+const (
+	Audio64K        AudioQuality = 30216 // 64K
+	Audio132K       AudioQuality = 30232 // 132K
+	Audio192K       AudioQuality = 30280 // 192K
+	AudioDolbyAtmos AudioQuality = 30250 // 杜比全景声
+	AudioHiRes      AudioQuality = 30251 // Hi-Res 无损
+)
+
+// Codec 是视频编码代码，即 AudioOrVideo 的 Codecid
+type Codec int
+
+const (
+	CodecAVC  Codec = 7  // H.264
+	CodecHEVC Codec = 12 // H.265
+	CodecAV1  Codec = 13 // AV1
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecAVC:
+		return "AVC"
+	case CodecHEVC:
+		return "HEVC"
+	case CodecAV1:
+		return "AV1"
+	default:
+		return "Codec(" + strconv.Itoa(int(c)) + ")"
+	}
+}
+
+// Fnval 是视频流格式标识，多个标识使用 | 组合，例如 FnvalDash | FnvalHDR | Fnval4K。使用 GetVideoStreamParam.WithFnval 设置。
+type Fnval int
+
+const (
+	FnvalMP4         Fnval = 1    // MP4 格式，与 FnvalDash 互斥
+	FnvalDash        Fnval = 16   // DASH 格式
+	FnvalHDR         Fnval = 64   // 是否需要 HDR 视频，需要 DASH 格式
+	Fnval4K          Fnval = 128  // 是否需要 4K 分辨率，需要 DASH 格式
+	FnvalDolbyAudio  Fnval = 256  // 是否需要杜比全景声，需要 DASH 格式
+	FnvalDolbyVision Fnval = 512  // 是否需要杜比视界，需要 DASH 格式
+	Fnval8K          Fnval = 1024 // 是否需要 8K 分辨率，需要 DASH 格式
+	FnvalAV1         Fnval = 2048 // 是否需要 AV1 编码，需要 DASH 格式
+
+	// FnvalDashAll 是 DASH 格式的全部标识
+	FnvalDashAll = FnvalDash | FnvalHDR | Fnval4K | FnvalDolbyAudio | FnvalDolbyVision | Fnval8K | FnvalAV1
+)
+
+// WithFnval 返回设置了 Fnval 的参数。需要 4K 或 8K 时还会设置 Fourk 为1
+func (param GetVideoStreamParam) WithFnval(fnval Fnval) GetVideoStreamParam {
+	param.Fnval = int(fnval)
+	if fnval&(Fnval4K|Fnval8K) != 0 {
+		param.Fourk = 1
+	}
+	return param
+}
+
+// Quality 返回视频流的清晰度
+func (s *AudioOrVideo) Quality() Quality {
+	return Quality(s.Id)
+}
+
+// AudioQuality 返回音频流的音质
+func (s *AudioOrVideo) AudioQuality() AudioQuality {
+	return AudioQuality(s.Id)
+}
+
+// Codec 返回视频流的编码，音频流为0
+func (s *AudioOrVideo) Codec() Codec {
+	return Codec(s.Codecid)
+}
+
+// StreamPreference 是 SelectStreams 选择视频流和音频流的偏好，零值表示选择最高清晰度的 SDR 视频流和普通音频流
+type StreamPreference struct {
+	MaxQuality        Quality // 最高清晰度，为0时不限制
+	MaxHeight         int     // 视频的最大高度，例如 1080，为0时不限制。不限制高度未知的视频流
+	MaxBandwidth      int     // 视频流的最大带宽，为0时不限制
+	Codecs            []Codec // 编码的优先顺序，例如 []Codec{CodecAV1, CodecHEVC, CodecAVC}，不在其中的编码排在最后。为空时优先 AVC，兼容性最好
+	PreferHDR         bool    // 优先选择 HDR 视频流，否则只在没有其它视频流时选择
+	PreferDolbyVision bool    // 优先选择杜比视界视频流，否则只在没有其它视频流时选择
+	PreferHiRes       bool    // 优先选择 Hi-Res 无损音轨，否则只在没有其它音频流时选择
+	PreferDolbyAtmos  bool    // 优先选择杜比全景声音轨，否则只在没有其它音频流时选择
+}
+
+var defaultCodecs = []Codec{CodecAVC, CodecHEVC, CodecAV1}
+
+// SelectStreams 按照 pref 从结果中选出最合适的视频流和音频流。
+//
+// 视频流依次比较：是否是偏好的 HDR 或杜比视界、清晰度、编码的优先顺序、带宽。音频流依次比较：是否是偏好的无损或杜比音轨、带宽。
+// 没有音频流时 audio 为 nil。没有满足限制的视频流时返回错误。
+//
+// 结果是 FLV 或 MP4 格式（只有 Durl）时，video 是包含了音频的整个视频，audio 为 nil。这时一次请求只返回一种清晰度，
+// 超出限制时返回错误，可以使用 SelectQuality 的结果作为 Qn 重新请求。分为多段的 FLV 不能表示为一个视频流，也会返回错误。
+func (r *GetVideoStreamResult) SelectStreams(pref StreamPreference) (video, audio *AudioOrVideo, err error) {
+	if len(r.Dash.Video) == 0 {
+		video, err = r.durlStream(pref)
+		return video, nil, err
+	}
+	codecs := pref.Codecs
+	if len(codecs) == 0 {
+		codecs = defaultCodecs
+	}
+	var videos []*AudioOrVideo
+	for i := range r.Dash.Video {
+		v := &r.Dash.Video[i]
+		if !pref.allows(v) {
+			continue
+		}
+		videos = append(videos, v)
+	}
+	if len(videos) == 0 {
+		return nil, nil, errors.New("没有满足要求的视频流")
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		a, b := videos[i], videos[j]
+		if sa, sb := videoScore(a, pref), videoScore(b, pref); sa != sb {
+			return sa > sb
+		}
+		if ha, hb := a.Quality().Height(), b.Quality().Height(); ha != hb {
+			return ha > hb
+		}
+		if a.Id != b.Id {
+			return a.Id > b.Id
+		}
+		if ca, cb := codecRank(a.Codec(), codecs), codecRank(b.Codec(), codecs); ca != cb {
+			return ca < cb
+		}
+		return a.Bandwidth > b.Bandwidth
+	})
+
+	audios := dashAudios(&r.Dash)
+	sort.SliceStable(audios, func(i, j int) bool {
+		a, b := audios[i], audios[j]
+		if sa, sb := audioScore(a, pref), audioScore(b, pref); sa != sb {
+			return sa > sb
+		}
+		return a.Bandwidth > b.Bandwidth
+	})
+	if len(audios) > 0 {
+		audio = audios[0]
+	}
+	return videos[0], audio, nil
+}
+
+// allows 判断视频流是否满足清晰度、高度和带宽的限制
+func (pref StreamPreference) allows(v *AudioOrVideo) bool {
+	height := v.Height
+	if height == 0 {
+		height = v.Quality().Height()
+	}
+	return (pref.MaxQuality <= 0 || v.Quality() <= pref.MaxQuality) &&
+		(pref.MaxHeight <= 0 || height == 0 || height <= pref.MaxHeight) &&
+		(pref.MaxBandwidth <= 0 || v.Bandwidth <= pref.MaxBandwidth)
+}
+
+// durlStream 把 FLV 或 MP4 格式的结果表示为一个视频流
+func (r *GetVideoStreamResult) durlStream(pref StreamPreference) (*AudioOrVideo, error) {
+	switch {
+	case len(r.Durl) == 0:
+		return nil, errors.New("结果中没有视频流")
+	case len(r.Durl) > 1:
+		return nil, errors.Errorf("视频分为%d段，请直接使用 Durl，或者请求时设置 FnvalDash", len(r.Durl))
+	}
+	durl := r.Durl[0]
+	mimeType := "video/mp4"
+	if strings.HasPrefix(r.Format, "flv") {
+		mimeType = "video/x-flv"
+	}
+	v := &AudioOrVideo{
+		Id:        r.Quality,
+		BaseUrl:   durl.Url,
+		BackupUrl: durl.BackupUrl,
+		MimeType:  mimeType,
+		Codecid:   r.VideoCodecid,
+		Height:    Quality(r.Quality).Height(),
+	}
+	if !pref.allows(v) {
+		return nil, errors.Errorf("视频流的清晰度 %s 超出了限制，可以使用 SelectQuality 的结果作为 Qn 重新请求", v.Quality())
+	}
+	return v, nil
+}
+
+// SelectQuality 按照 pref 从 SupportFormats 中选出视频支持的最合适的清晰度，用于设置 GetVideoStreamParam 的 Qn。
+// 只考虑清晰度、高度和对 HDR、杜比视界的偏好，没有满足限制的清晰度时返回 false。
+//
+// 支持的清晰度不一定都能获取，例如 1080P+ 需要大会员，4K 需要设置 Fnval4K。
+func (r *GetVideoStreamResult) SelectQuality(pref StreamPreference) (Quality, bool) {
+	var best *AudioOrVideo
+	for _, f := range r.SupportFormats {
+		v := &AudioOrVideo{Id: f.Quality}
+		if !pref.allows(v) {
+			continue
+		}
+		if best == nil || videoScore(v, pref) > videoScore(best, pref) ||
+			videoScore(v, pref) == videoScore(best, pref) && v.Id > best.Id {
+			best = v
+		}
+	}
+	if best == nil {
+		return 0, false
+	}
+	return best.Quality(), true
+}
+
+// videoScore 偏好的 HDR 或杜比视界为1，普通视频流为0，不偏好的 HDR 或杜比视界为-1
+func videoScore(v *AudioOrVideo, pref StreamPreference) int {
+	switch v.Quality() {
+	case QualityHDR:
+		if pref.PreferHDR {
+			return 1
+		}
+		return -1
+	case QualityDolbyVision:
+		if pref.PreferDolbyVision {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+// audioScore 偏好的无损或杜比音轨为1，普通音频流为0，不偏好的无损或杜比音轨为-1
+func audioScore(a *AudioOrVideo, pref StreamPreference) int {
+	switch a.AudioQuality() {
+	case AudioHiRes:
+		if pref.PreferHiRes {
+			return 1
+		}
+		return -1
+	case AudioDolbyAtmos:
+		if pref.PreferDolbyAtmos {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+func codecRank(codec Codec, codecs []Codec) int {
+	for i, c := range codecs {
+		if c == codec {
+			return i
+		}
+	}
+	return len(codecs)
+}
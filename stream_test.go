@@ -0,0 +1,109 @@
+package bilibili
+
+import "testing"
+
+func TestSelectStreams(t *testing.T) {
+	result := &GetVideoStreamResult{Dash: Dash{
+		Video: []AudioOrVideo{
+			{Id: 80, Codecid: 7, Height: 1080, Bandwidth: 3000},
+			{Id: 80, Codecid: 12, Height: 1080, Bandwidth: 1500},
+			{Id: 80, Codecid: 13, Height: 1080, Bandwidth: 1000},
+			{Id: 120, Codecid: 12, Height: 2160, Bandwidth: 12000},
+			{Id: 125, Codecid: 12, Height: 2160, Bandwidth: 15000},
+			{Id: 64, Codecid: 7, Height: 720, Bandwidth: 1500},
+		},
+		Audio: []AudioOrVideo{{Id: 30216, Bandwidth: 64}, {Id: 30280, Bandwidth: 192}},
+		Dolby: Dolby{Audio: []AudioOrVideo{{Id: 30250, Bandwidth: 448}}},
+		Flac:  Flac{Audio: AudioOrVideo{Id: 30251, Bandwidth: 1000}},
+	}}
+
+	for _, c := range []struct {
+		pref    StreamPreference
+		quality Quality
+		codec   Codec
+		audio   AudioQuality
+	}{
+		{StreamPreference{}, Quality4K, CodecHEVC, Audio192K},
+		{StreamPreference{PreferHDR: true, PreferHiRes: true}, QualityHDR, CodecHEVC, AudioHiRes},
+		{StreamPreference{MaxHeight: 1080, PreferDolbyAtmos: true}, Quality1080P, CodecAVC, AudioDolbyAtmos},
+		{StreamPreference{MaxQuality: Quality1080P, Codecs: []Codec{CodecAV1, CodecHEVC}}, Quality1080P, CodecAV1, Audio192K},
+		{StreamPreference{MaxBandwidth: 2000}, Quality1080P, CodecHEVC, Audio192K},
+	} {
+		video, audio, err := result.SelectStreams(c.pref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if video.Quality() != c.quality || video.Codec() != c.codec || audio.AudioQuality() != c.audio {
+			t.Fatalf("%+v: expected %s %s %d, got %s %s %d", c.pref, c.quality, c.codec, c.audio, video.Quality(), video.Codec(), audio.AudioQuality())
+		}
+	}
+
+	if _, _, err := result.SelectStreams(StreamPreference{MaxHeight: 480}); err == nil {
+		t.Fatal("expected error when no video stream matches")
+	}
+	if _, _, err := (&GetVideoStreamResult{}).SelectStreams(StreamPreference{}); err == nil {
+		t.Fatal("expected error for empty result")
+	}
+
+	param := GetVideoStreamParam{Cid: 1}.WithFnval(FnvalDash | FnvalHDR | Fnval4K)
+	if param.Fnval != 208 || param.Fourk != 1 {
+		t.Fatal("WithFnval not correct ", param)
+	}
+	if FnvalDashAll != 4048 {
+		t.Fatal("FnvalDashAll not correct ", FnvalDashAll)
+	}
+}
+
+func TestSelectStreamsDurl(t *testing.T) {
+	result := &GetVideoStreamResult{
+		Quality:      80,
+		Format:       "mp4",
+		VideoCodecid: 7,
+		Durl:         []Durl{{Order: 1, Url: "https://upos/a.mp4", BackupUrl: []string{"https://mirror/a.mp4"}}},
+		SupportFormats: []SupportFormat{
+			{Quality: 126}, {Quality: 120}, {Quality: 116}, {Quality: 80}, {Quality: 64}, {Quality: 32}, {Quality: 16},
+		},
+	}
+	video, audio, err := result.SelectStreams(StreamPreference{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audio != nil || video.Quality() != Quality1080P || video.Codec() != CodecAVC || video.BaseUrl != "https://upos/a.mp4" ||
+		len(video.BackupUrl) != 1 || video.MimeType != "video/mp4" {
+		t.Fatalf("durl stream not correct %+v", video)
+	}
+
+	// 超出限制时需要使用 SelectQuality 的结果重新请求
+	if _, _, err = result.SelectStreams(StreamPreference{MaxHeight: 720}); err == nil {
+		t.Fatal("expected error when durl quality exceeds the limit")
+	}
+	for _, c := range []struct {
+		pref    StreamPreference
+		quality Quality
+	}{
+		{StreamPreference{}, Quality4K},
+		{StreamPreference{PreferDolbyVision: true}, QualityDolbyVision},
+		{StreamPreference{MaxHeight: 720}, Quality720P},
+		{StreamPreference{MaxHeight: 1080, PreferDolbyVision: true}, QualityDolbyVision}, // 杜比视界的高度未知，不受 MaxHeight 限制
+		{StreamPreference{MaxQuality: Quality1080PPlus}, Quality1080P},
+	} {
+		if q, ok := result.SelectQuality(c.pref); !ok || q != c.quality {
+			t.Fatalf("%+v: expected %s, got %s", c.pref, c.quality, q)
+		}
+	}
+	if QualityHDR.Height() != 0 || QualityDolbyVision.Height() != 0 || Quality4K.Height() != 2160 {
+		t.Fatal("unexpected height of HDR, Dolby Vision or 4K")
+	}
+	// 只有高度未知的杜比视界满足 MaxHeight
+	if q, ok := result.SelectQuality(StreamPreference{MaxHeight: 240}); !ok || q != QualityDolbyVision {
+		t.Fatal("expected Dolby Vision for MaxHeight 240, got ", q)
+	}
+	if _, ok := result.SelectQuality(StreamPreference{MaxHeight: 240, MaxQuality: Quality4K}); ok {
+		t.Fatal("expected no quality for MaxHeight 240")
+	}
+
+	result.Durl = append(result.Durl, Durl{Order: 2})
+	if _, _, err = result.SelectStreams(StreamPreference{}); err == nil {
+		t.Fatal("expected error for segmented flv")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// generator 为一个接口生成参数结构体、返回值结构体和方法
+type generator struct {
+	ep         *endpoint
+	name       string // 方法名
+	paramName  string // 参数结构体名
+	resultName string // 返回值结构体名
+	types      []string
+	typeNames  map[string]bool
+}
+
+// generate 生成 Go 代码，包括 package 声明和 import，已经经过 gofmt
+func generate(ep *endpoint, pkg, name, paramName, resultName string) ([]byte, error) {
+	if len(paramName) == 0 {
+		paramName = name + "Param"
+	}
+	if len(resultName) == 0 {
+		// 与 CONTRIBUTING.md 一致：Get 类的函数省略 Get 和 Result
+		if strings.HasPrefix(name, "Get") && len(name) > 3 {
+			resultName = strings.TrimPrefix(name, "Get")
+		} else {
+			resultName = name + "Result"
+		}
+	}
+	g := &generator{ep: ep, name: name, paramName: paramName, resultName: resultName, typeNames: make(map[string]bool)}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n\t\"github.com/go-resty/resty/v2\"\n)\n\n", pkg)
+	hasParam := g.paramStruct(&buf)
+	resultType := g.resultType()
+	for _, t := range g.types {
+		buf.WriteString(t)
+	}
+	g.method(&buf, hasParam, resultType)
+	return format.Source(buf.Bytes())
+}
+
+// paramStruct 生成参数结构体，没有参数时返回 false
+func (g *generator) paramStruct(buf *bytes.Buffer) bool {
+	if len(g.ep.Params) == 0 {
+		return false
+	}
+	fmt.Fprintf(buf, "type %s struct {\n", g.paramName)
+	for _, p := range g.ep.Params {
+		tag := `json:"` + p.Name
+		switch {
+		case isRequired(p.Required) && p.location == "query":
+			tag += `"`
+		case isRequired(p.Required):
+			tag += `" request:"` + p.location + `"`
+		default:
+			tag += `,omitempty" request:"` + p.location + `,omitempty"`
+		}
+		fmt.Fprintf(buf, "\t%s %s `%s`%s\n", fieldName(p.Name), paramType(p.Type), tag, comment(p.field))
+	}
+	buf.WriteString("}\n\n")
+	g.typeNames[g.paramName] = true
+	return true
+}
+
+// resultType 生成返回值的结构体，返回方法的返回值类型，接口没有返回 data 时返回空字符串
+func (g *generator) resultType() string {
+	for _, f := range g.ep.Objects[""] {
+		if f.Name != "data" {
+			continue
+		}
+		t := g.goType("data", f, g.resultName)
+		if strings.HasPrefix(t, "[]") || t == "any" || !g.typeNames[t] {
+			return t
+		}
+		return "*" + t
+	}
+	if fields, ok := g.ep.Objects["data"]; ok {
+		return "*" + g.defineStruct(g.resultName, "data", fields)
+	}
+	return ""
+}
+
+// goType 返回响应中 path 处的字段 f 的类型，需要时以 typeName 为名定义结构体
+func (g *generator) goType(path string, f field, typeName string) string {
+	typ := strings.ToLower(strings.TrimSpace(f.Type))
+	switch {
+	case typ == "num" || typ == "number" || typ == "int":
+		return "int"
+	case typ == "str" || typ == "string":
+		return "string"
+	case typ == "bool" || typ == "boolean":
+		return "bool"
+	case typ == "obj" || typ == "object":
+		if fields, ok := g.ep.Objects[path]; ok {
+			return g.defineStruct(typeName, path, fields)
+		}
+		return "any"
+	case strings.HasPrefix(typ, "array") || strings.HasPrefix(typ, "list"):
+		elemName := singular(typeName)
+		if i := strings.Index(typ, "("); i >= 0 {
+			elem := strings.TrimSuffix(typ[i+1:], ")")
+			return "[]" + g.goType(path+"[]", field{Type: elem}, elemName)
+		}
+		if items := g.ep.Arrays[path]; len(items) > 0 {
+			return "[]" + g.goType(path+"[]", items[0], elemName)
+		}
+		if fields, ok := g.ep.Objects[path+"[]"]; ok {
+			return "[]" + g.defineStruct(elemName, path+"[]", fields)
+		}
+		return "[]any"
+	default:
+		return "any"
+	}
+}
+
+// defineStruct 定义一个结构体，重名时在名字后面加上数字，返回实际的名字
+func (g *generator) defineStruct(name, path string, fields []field) string {
+	for i := 2; g.typeNames[name]; i++ {
+		name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+	}
+	g.typeNames[name] = true
+	index := len(g.types)
+	g.types = append(g.types, "")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "type %s struct {\n", name)
+	for _, f := range fields {
+		fieldPath := f.Name
+		if len(path) > 0 {
+			fieldPath = path + "." + f.Name
+		}
+		typ := g.goType(fieldPath, f, name+fieldName(f.Name))
+		fmt.Fprintf(&buf, "\t%s %s `json:\"%s\"`%s\n", fieldName(f.Name), typ, f.Name, comment(f))
+	}
+	buf.WriteString("}\n\n")
+	g.types[index] = buf.String()
+	return name
+}
+
+// method 生成调用接口的方法
+func (g *generator) method(buf *bytes.Buffer, hasParam bool, resultType string) {
+	method := "resty.MethodGet"
+	if g.ep.Method == "POST" {
+		method = "resty.MethodPost"
+	}
+	execute := "execute"
+	if g.ep.Wbi {
+		execute = "executeWbi"
+	}
+	arg, in := "", "nil"
+	if hasParam {
+		arg, in = "param "+g.paramName, "param"
+	}
+	handlers := ""
+	if g.ep.Csrf {
+		handlers = ", fillCsrf(c)"
+	}
+
+	fmt.Fprintf(buf, "// %s %s\n", g.name, g.ep.Title)
+	if len(resultType) == 0 {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) error {\n", g.name, arg)
+	} else {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) (%s, error) {\n", g.name, arg, resultType)
+	}
+	fmt.Fprintf(buf, "\tconst (\n\t\tmethod = %s\n\t\turl = %q\n\t)\n", method, g.ep.Url)
+	if len(resultType) == 0 {
+		fmt.Fprintf(buf, "\t_, err := %s[any](c, method, url, %s%s)\n\treturn err\n}\n", execute, in, handlers)
+	} else {
+		fmt.Fprintf(buf, "\treturn %s[%s](c, method, url, %s%s)\n}\n", execute, resultType, in, handlers)
+	}
+}
+
+// isRequired 判断参数是否必要，必要性为空的参数视为可选
+func isRequired(required string) bool {
+	switch strings.TrimSpace(required) {
+	case "必要", "必须", "必填", "√":
+		return true
+	}
+	return false
+}
+
+func paramType(typ string) string {
+	switch strings.ToLower(strings.TrimSpace(typ)) {
+	case "num", "number", "int":
+		return "int"
+	case "str", "string":
+		return "string"
+	case "bool", "boolean":
+		return "bool"
+	case "array(num)", "list(num)":
+		return "[]int"
+	case "array", "list", "array(str)", "list(str)":
+		return "[]string"
+	default:
+		return "any"
+	}
+}
+
+// fieldName 把 snake_case 的字段名转换为 Go 的字段名，例如 "image_urls" 转换为 "ImageUrls"
+func fieldName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if len(s) == 0 || unicode.IsDigit(rune(s[0])) {
+		s = "F" + s
+	}
+	return s
+}
+
+// singular 返回数组元素的类型名，与原来的 gen_struct.py 一样去掉结尾的 s
+func singular(name string) string {
+	if len(name) > 1 && strings.HasSuffix(name, "s") {
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// comment 生成字段的注释，由内容和备注组成
+func comment(f field) string {
+	c := f.Content
+	if len(c) > 0 && len(f.Comment) > 0 {
+		c += "。"
+	}
+	c += f.Comment
+	if len(c) == 0 {
+		return ""
+	}
+	c = strings.NewReplacer("<br />", "。", "<br/>", "。", "<br>", "。", "\\", "").Replace(c)
+	return " // " + c
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const videoDoc = "# 视频基本信息\n\n" +
+	"## 获取视频详细信息(web端)\n\n" +
+	"> https://api.bilibili.com/x/web-interface/wbi/view\n\n" +
+	"*请求方式：GET*\n\n" +
+	"鉴权方式：[Wbi 签名](../misc/sign/wbi.md)\n\n" +
+	"**url参数：**\n\n" +
+	"| 参数名 | 类型 | 内容     | 必要性       | 备注               |\n" +
+	"| ------ | ---- | -------- | ------------ | ------------------ |\n" +
+	"| aid    | num  | 稿件avid | 必要（可选） | avid与bvid任选一个 |\n" +
+	"| bvid   | str  | 稿件bvid | 必要（可选） | avid与bvid任选一个 |\n\n" +
+	"**json回复：**\n\n" +
+	"根对象：\n\n" +
+	"| 字段    | 类型 | 内容     | 备注 |\n" +
+	"| ------- | ---- | -------- | ---- |\n" +
+	"| code    | num  | 返回值   | 0：成功<br />-400：请求错误 |\n" +
+	"| message | str  | 错误信息 | 默认为0 |\n" +
+	"| data    | obj  | 信息本体 |      |\n\n" +
+	"`data`对象：\n\n" +
+	"| 字段  | 类型  | 内容        | 备注 |\n" +
+	"| ----- | ----- | ----------- | ---- |\n" +
+	"| bvid  | str   | 稿件bvid    |      |\n" +
+	"| owner | obj   | UP主信息    |      |\n" +
+	"| pages | array | 视频分P列表 |      |\n" +
+	"| tags  | array | 标签        |      |\n\n" +
+	"`data`中的`owner`对象：\n\n" +
+	"| 字段 | 类型 | 内容    | 备注 |\n" +
+	"| ---- | ---- | ------- | ---- |\n" +
+	"| mid  | num  | UP主mid |      |\n\n" +
+	"`data`中的`pages`数组：\n\n" +
+	"| 项 | 类型 | 内容     | 备注 |\n" +
+	"| -- | ---- | -------- | ---- |\n" +
+	"| 0  | obj  | 第1分P   |      |\n\n" +
+	"`data`中的`pages`数组中的对象：\n\n" +
+	"| 字段 | 类型 | 内容      | 备注 |\n" +
+	"| ---- | ---- | --------- | ---- |\n" +
+	"| cid  | num  | 分P的cid  |      |\n" +
+	"| part | str  | 分P的标题 |      |\n\n" +
+	"`data`中的`tags`数组：\n\n" +
+	"| 项 | 类型 | 内容 | 备注 |\n" +
+	"| -- | ---- | ---- | ---- |\n" +
+	"| 0  | str  | 标签 |      |\n\n" +
+	"## 点赞视频(web端)\n\n" +
+	"> https://api.bilibili.com/x/web-interface/archive/like\n\n" +
+	"*请求方式：POST*\n\n" +
+	"**正文参数（ application/x-www-form-urlencoded ）：**\n\n" +
+	"| 参数名 | 类型 | 内容                 | 必要性 | 备注 |\n" +
+	"| ------ | ---- | -------------------- | ------ | ---- |\n" +
+	"| aid    | num  | 稿件avid             | 必要   |      |\n" +
+	"| like   | num  | 操作方式             | 必要   | 1：点赞<br />2：取消赞 |\n" +
+	"| source | str  | 来源                 |        |      |\n" +
+	"| csrf   | str  | CSRF Token（bili_jct） | 必要 |      |\n\n" +
+	"**json回复：**\n\n" +
+	"根对象：\n\n" +
+	"| 字段    | 类型 | 内容     | 备注 |\n" +
+	"| ------- | ---- | -------- | ---- |\n" +
+	"| code    | num  | 返回值   |      |\n" +
+	"| message | str  | 错误信息 |      |\n"
+
+func TestGenerate(t *testing.T) {
+	endpoints, err := parse(strings.NewReader(videoDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatal("expected 2 endpoints, got ", len(endpoints))
+	}
+	view, like := endpoints[0], endpoints[1]
+	if !view.Wbi || view.Method != "GET" || view.Url != "https://api.bilibili.com/x/web-interface/wbi/view" {
+		t.Fatal("endpoint not parsed correctly ", view)
+	}
+	if !like.Csrf || like.Method != "POST" || len(like.Params) != 3 {
+		t.Fatal("endpoint not parsed correctly ", like)
+	}
+
+	code, err := generate(view, "bilibili", "GetVideoInfo", "VideoParam", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"type VideoParam struct {\n\tAid  int    `json:\"aid,omitempty\" request:\"query,omitempty\"`  // 稿件avid。avid与bvid任选一个\n",
+		"type VideoInfo struct {\n",
+		"\tOwner VideoInfoOwner  `json:\"owner\"` // UP主信息\n",
+		"\tPages []VideoInfoPage `json:\"pages\"` // 视频分P列表\n",
+		"\tTags  []string        `json:\"tags\"`  // 标签\n",
+		"type VideoInfoPage struct {\n\tCid  int    `json:\"cid\"`  // 分P的cid\n",
+		"// GetVideoInfo 获取视频详细信息(web端)\nfunc (c *Client) GetVideoInfo(param VideoParam) (*VideoInfo, error) {\n",
+		"\treturn executeWbi[*VideoInfo](c, method, url, param)\n",
+	} {
+		if !strings.Contains(string(code), expected) {
+			t.Fatalf("generated code should contain:\n%s\ngot:\n%s", expected, code)
+		}
+	}
+
+	code, err = generate(like, "bilibili", "LikeVideo", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"\tLike   int    `json:\"like\"`                                       // 操作方式。1：点赞。2：取消赞\n",
+		// 必要性为空的参数是可选的
+		"\tSource string `json:\"source,omitempty\" request:\"query,omitempty\"` // 来源\n",
+		"func (c *Client) LikeVideo(param LikeVideoParam) error {\n",
+		"\t\tmethod = resty.MethodPost\n",
+		"\t_, err := execute[any](c, method, url, param, fillCsrf(c))\n",
+	} {
+		if !strings.Contains(string(code), expected) {
+			t.Fatalf("generated code should contain:\n%s\ngot:\n%s", expected, code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// genapi 根据 bilibili-API-collect 风格的 Markdown 文档生成接口的参数结构体、返回值结构体和 Client 的方法。
+//
+// 用法：
+//
+//	go run ./tools/genapi -name GetVideoInfo [-title 获取视频详细信息] [-param VideoParam] [-result VideoInfo] [-o video_info.go] doc.md
+//
+// 没有指定文档时从标准输入读取。文档中有多个接口时，使用 -title 按标题选择，默认使用第一个接口。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	var (
+		name       = flag.String("name", "", "生成的方法名，例如 GetVideoInfo（必填）")
+		title      = flag.String("title", "", "文档中有多个接口时，选择标题包含该字符串的接口")
+		paramName  = flag.String("param", "", "参数结构体名，默认为 <name>Param")
+		resultName = flag.String("result", "", "返回值结构体名，默认为 <name>Result，Get 类的函数省略 Get 和 Result")
+		pkg        = flag.String("pkg", "bilibili", "生成的代码的包名")
+		output     = flag.String("o", "", "输出文件，默认输出到标准输出")
+	)
+	flag.Parse()
+	if len(*name) == 0 || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*name, *title, *paramName, *resultName, *pkg, *output, flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "genapi:", err)
+		os.Exit(1)
+	}
+}
+
+func run(name, title, paramName, resultName, pkg, output, input string) error {
+	var r io.Reader = os.Stdin
+	if len(input) > 0 {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	endpoints, err := parse(r)
+	if err != nil {
+		return err
+	}
+	var ep *endpoint
+	for _, e := range endpoints {
+		if strings.Contains(e.Title, title) {
+			ep = e
+			break
+		}
+	}
+	if ep == nil {
+		return fmt.Errorf("文档中没有找到接口")
+	}
+	code, err := generate(ep, pkg, name, paramName, resultName)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(output, code, 0644)
+}
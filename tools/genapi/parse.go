@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// field 是文档表格中的一行
+type field struct {
+	Name     string
+	Type     string
+	Content  string
+	Required string
+	Comment  string
+}
+
+// param 是一个请求参数，location 是 withParams 的 request 标签：query、json 或 form-data
+type param struct {
+	field
+	location string
+}
+
+// endpoint 是文档中的一个接口
+type endpoint struct {
+	Title  string
+	Url    string
+	Method string // GET 或 POST
+	Wbi    bool   // 需要 Wbi 签名
+	Csrf   bool   // 需要 csrf，参数中的 csrf 会由 fillCsrf 填充
+	Params []param
+
+	// 响应中的对象和数组的表格，key 是以 . 分隔的路径，数组中的对象在路径后面加 []，例如 "data.pages[]"。根对象的路径为空
+	Objects map[string][]field
+	Arrays  map[string][]field
+}
+
+var headMapping = map[string]string{
+	"参数名": "name",
+	"字段":  "name",
+	"字段名": "name",
+	"项":   "name",
+	"类型":  "type",
+	"内容":  "content",
+	"必要性": "required",
+	"备注":  "comment",
+}
+
+var regUrl = regexp.MustCompile(`https?://[^\s` + "`" + `?#]+`)
+
+// parse 解析 bilibili-API-collect 风格的 Markdown 文档，返回其中所有带有接口地址的接口
+func parse(r io.Reader) ([]*endpoint, error) {
+	var (
+		endpoints []*endpoint
+		cur       *endpoint
+		title     string
+		inCode    bool
+		// 下一个表格的用途
+		location     string // 请求参数表格的位置，为空表示不是请求参数
+		inResponse   bool
+		responsePath string
+		isArray      bool
+		tableLines   []string
+	)
+	flushTable := func() {
+		if len(tableLines) == 0 || cur == nil {
+			tableLines = nil
+			return
+		}
+		fields := parseTable(tableLines)
+		tableLines = nil
+		switch {
+		case len(location) > 0:
+			for _, f := range fields {
+				if f.Name == "csrf" || f.Name == "csrf_token" {
+					cur.Csrf = true
+					continue
+				}
+				cur.Params = append(cur.Params, param{field: f, location: location})
+			}
+			location = ""
+		case inResponse:
+			if isArray {
+				cur.Arrays[responsePath] = fields
+			} else {
+				cur.Objects[responsePath] = fields
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if strings.HasPrefix(line, "|") {
+			tableLines = append(tableLines, line)
+			continue
+		}
+		flushTable()
+
+		switch {
+		case strings.HasPrefix(line, "#"):
+			title = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			inResponse, location = false, ""
+		case strings.HasPrefix(line, ">") && regUrl.MatchString(line):
+			cur = &endpoint{
+				Title:   title,
+				Url:     regUrl.FindString(line),
+				Method:  "GET",
+				Objects: make(map[string][]field),
+				Arrays:  make(map[string][]field),
+			}
+			endpoints = append(endpoints, cur)
+			inResponse, location = false, ""
+		case cur == nil:
+		case strings.Contains(line, "请求方式"):
+			if strings.Contains(strings.ToUpper(line), "POST") {
+				cur.Method = "POST"
+			}
+		case strings.Contains(line, "鉴权方式") && strings.Contains(strings.ToLower(line), "wbi"):
+			cur.Wbi = true
+		case strings.Contains(line, "参数") && strings.HasPrefix(line, "**"):
+			inResponse = false
+			location = paramLocation(line)
+		case strings.Contains(line, "回复") && strings.HasPrefix(line, "**"):
+			inResponse, location = true, ""
+		case inResponse && strings.HasPrefix(line, "根对象"):
+			responsePath, isArray = "", false
+		case inResponse && strings.Contains(line, "`") && (strings.HasSuffix(line, "：") || strings.HasSuffix(line, ":")):
+			responsePath, isArray = parseObjectTitle(line)
+		}
+	}
+	flushTable()
+	return endpoints, scanner.Err()
+}
+
+// paramLocation 根据参数表格的标题判断参数的位置，例如 "**正文参数（ application/json ）：**"
+func paramLocation(line string) string {
+	lower := strings.ToLower(line)
+	switch {
+	case !strings.Contains(line, "正文"):
+		return "query"
+	case strings.Contains(lower, "json"):
+		return "json"
+	case strings.Contains(lower, "form-data"):
+		return "form-data"
+	default:
+		return "query"
+	}
+}
+
+// parseObjectTitle 解析对象表格的标题，例如 "`data`中的`list`数组中的对象中的`stat`对象："，返回路径和它是否是数组
+func parseObjectTitle(line string) (path string, isArray bool) {
+	line = strings.TrimRight(line, "：:")
+	parts := strings.Split(line, "`")
+	var names []string
+	for i := 1; i < len(parts); i += 2 {
+		name := parts[i]
+		if i+1 < len(parts) && strings.HasPrefix(parts[i+1], "数组中的对象") {
+			name += "[]"
+		}
+		names = append(names, name)
+	}
+	last := parts[len(parts)-1]
+	isArray = strings.HasSuffix(last, "数组") && !strings.HasSuffix(last, "数组中的对象")
+	return strings.Join(names, "."), isArray
+}
+
+// parseTable 解析 Markdown 表格，第一行是表头，跳过分隔行
+func parseTable(lines []string) []field {
+	var head []string
+	var fields []field
+	for _, line := range lines {
+		cells := splitRow(line)
+		if head == nil {
+			for _, cell := range cells {
+				head = append(head, headMapping[cell])
+			}
+			continue
+		}
+		if isSeparator(cells[0]) {
+			continue
+		}
+		var f field
+		for i, cell := range cells {
+			if i >= len(head) {
+				break
+			}
+			switch head[i] {
+			case "name":
+				f.Name = cell
+			case "type":
+				f.Type = cell
+			case "content":
+				f.Content = cell
+			case "required":
+				f.Required = cell
+			case "comment":
+				f.Comment = cell
+			}
+		}
+		if len(f.Name) > 0 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(strings.ReplaceAll(cell, "`", ""))
+	}
+	return cells
+}
+
+// isSeparator 判断是否是表头下面的分隔行，例如 "---"、":--"
+func isSeparator(cell string) bool {
+	return len(cell) > 0 && len(strings.Trim(cell, "-:")) == 0
+}
@@ -0,0 +1,297 @@
+package bilibili
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// URLKind 是 ParseURL 解析出的链接类型
+type URLKind int
+
+const (
+	URLUnknown        URLKind = iota
+	URLVideo                  // 视频，av号或bv号
+	URLBangumiEpisode         // 番剧、影视的剧集，ep号
+	URLBangumiSeason          // 番剧、影视的季度，ss号
+	URLArticle                // 专栏文章，cv号
+	URLReadList               // 专栏文集，rl号
+	URLSpace                  // 用户空间，mid
+	URLDynamic                // 动态，包括 t.bilibili.com 和 opus
+	URLFavFolder              // 收藏夹，ml号
+	URLAudio                  // 音频，au号
+	URLLive                   // 直播间，房间号
+)
+
+func (k URLKind) String() string {
+	switch k {
+	case URLVideo:
+		return "video"
+	case URLBangumiEpisode:
+		return "ep"
+	case URLBangumiSeason:
+		return "ss"
+	case URLArticle:
+		return "article"
+	case URLReadList:
+		return "readlist"
+	case URLSpace:
+		return "space"
+	case URLDynamic:
+		return "dynamic"
+	case URLFavFolder:
+		return "fav"
+	case URLAudio:
+		return "audio"
+	case URLLive:
+		return "live"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsedURL 是 ParseURL 的解析结果
+type ParsedURL struct {
+	Kind  URLKind
+	Id    int64         // 除视频以外的id：ep号、ss号、cv号、rl号、mid、动态id、收藏夹mlid、au号或直播间号
+	Video VideoID       // 视频的av号、bv号和分P，仅 URLVideo
+	Time  time.Duration // 视频链接中 t 参数指定的播放进度，仅 URLVideo
+	Tab   string        // 空间的子页面，例如 favlist、series、collection、channel、video、dynamic，仅 URLSpace
+	SubId int           // 空间子页面中的id：favlist 为收藏夹 fid，series 和 collection 为列表或合集的 sid，仅 URLSpace
+}
+
+var (
+	regVideoPath    = regexp.MustCompile(`(?i)^/(?:s/)?video/(av\d+|bv[\dA-Za-z]{10})`)
+	regBangumiPath  = regexp.MustCompile(`^/bangumi/play/(ep|ss)(\d+)`)
+	regArticlePath  = regexp.MustCompile(`^/read/(?:cv|mobile/)(\d+)`)
+	regReadListPath = regexp.MustCompile(`^/read/readlist/rl(\d+)`)
+	regOpusPath     = regexp.MustCompile(`^/(?:opus|dynamic)/(\d+)`)
+	regFavPath      = regexp.MustCompile(`^/(?:medialist/(?:detail|play)|list)/ml(\d+)`)
+	regAudioPath    = regexp.MustCompile(`^/audio/au(\d+)`)
+	regSpacePath    = regexp.MustCompile(`^/(?:space/)?(\d+)/?([^?]*)`)
+	regLivePath     = regexp.MustCompile(`^/(?:h5/|blanc/)?(\d+)`)
+	regDynamicPath  = regexp.MustCompile(`^/(\d+)`)
+	regListsPath    = regexp.MustCompile(`^lists/(\d+)`)
+	regIdToken      = regexp.MustCompile(`(?i)^(ep|ss|cv|rl|ml|au)(\d+)$`)
+)
+
+// ParseURL 在不发起请求的情况下解析B站的链接，例如：
+//
+//	https://www.bilibili.com/video/BV17x411w7KC?p=2&t=90
+//	https://www.bilibili.com/bangumi/play/ep123、https://www.bilibili.com/bangumi/play/ss123
+//	https://www.bilibili.com/read/cv123、https://www.bilibili.com/read/readlist/rl123
+//	https://space.bilibili.com/2/favlist?fid=123、https://space.bilibili.com/2/channel/seriesdetail?sid=123
+//	https://t.bilibili.com/123、https://www.bilibili.com/opus/123
+//	https://www.bilibili.com/medialist/detail/ml123、https://www.bilibili.com/audio/au123
+//	https://live.bilibili.com/123
+//
+// 也可以不带协议和域名，直接传入 av170001、BV17x411w7KC、ep123、ss123、cv123、rl123、ml123、au123 这样的编号。
+// b23.tv 的短链接需要发起请求，请使用 Client.ResolveURL。
+func ParseURL(rawUrl string) (ParsedURL, error) {
+	s := strings.TrimSpace(rawUrl)
+	if !strings.Contains(s, "/") {
+		return parseIdToken(s)
+	}
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "无法解析链接: %s", rawUrl)
+	}
+	host := strings.ToLower(u.Hostname())
+	if isShortUrlHost(host) {
+		return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "短链接请使用 Client.ResolveURL 解析: %s", rawUrl)
+	}
+	if host != "bilibili.com" && !strings.HasSuffix(host, ".bilibili.com") {
+		return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "不是B站的链接: %s", rawUrl)
+	}
+	path, query := u.Path, u.Query()
+
+	var result ParsedURL
+	switch host {
+	case "space.bilibili.com":
+		result, err = parseSpacePath(path, query)
+	case "t.bilibili.com":
+		result, err = matchId(URLDynamic, regDynamicPath, path)
+	case "live.bilibili.com":
+		result, err = matchId(URLLive, regLivePath, path)
+	default:
+		result, err = parseMainPath(path, query)
+	}
+	if err != nil {
+		return ParsedURL{}, err
+	}
+	if result.Kind == URLUnknown {
+		return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "无法识别的链接: %s", rawUrl)
+	}
+	return result, nil
+}
+
+// parseMainPath 解析 www.bilibili.com 和 m.bilibili.com 的链接
+func parseMainPath(path string, query url.Values) (ParsedURL, error) {
+	if m := regVideoPath.FindStringSubmatch(path); len(m) > 0 {
+		return parseVideoQuery(m[1], query)
+	}
+	if m := regBangumiPath.FindStringSubmatch(path); len(m) > 0 {
+		return parseIdToken(m[1] + m[2])
+	}
+	if strings.HasPrefix(path, "/read/mobile") && len(query.Get("id")) > 0 {
+		return parseId(URLArticle, query.Get("id"))
+	}
+	for _, p := range []struct {
+		kind URLKind
+		reg  *regexp.Regexp
+	}{
+		{URLArticle, regArticlePath},
+		{URLReadList, regReadListPath},
+		{URLDynamic, regOpusPath},
+		{URLFavFolder, regFavPath},
+		{URLAudio, regAudioPath},
+	} {
+		if result, err := matchId(p.kind, p.reg, path); err != nil || result.Kind != URLUnknown {
+			return result, err
+		}
+	}
+	if strings.HasPrefix(path, "/space/") {
+		return parseSpacePath(strings.TrimPrefix(path, "/space"), query)
+	}
+	return ParsedURL{}, nil
+}
+
+// parseVideoQuery 解析视频链接中的分P p 和播放进度 t
+func parseVideoQuery(number string, query url.Values) (ParsedURL, error) {
+	id, err := parseVideoNumber(number)
+	if err != nil {
+		return ParsedURL{}, err
+	}
+	result := ParsedURL{Kind: URLVideo, Video: id}
+	if p := query.Get("p"); len(p) > 0 {
+		if result.Video.Page, err = strconv.Atoi(p); err != nil || result.Video.Page < 1 {
+			return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "分P序号不正确: %s", p)
+		}
+	}
+	if t := query.Get("t"); len(t) > 0 {
+		// t 一般是秒数，也可能是小数或者 1m30s 这样的格式
+		if seconds, err := strconv.ParseFloat(t, 64); err == nil {
+			result.Time = time.Duration(seconds * float64(time.Second))
+		} else if result.Time, err = time.ParseDuration(t); err != nil {
+			return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "播放进度不正确: %s", t)
+		}
+	}
+	return result, nil
+}
+
+// parseSpacePath 解析用户空间的链接，path 以 /mid 开头
+func parseSpacePath(path string, query url.Values) (ParsedURL, error) {
+	m := regSpacePath.FindStringSubmatch(path)
+	if len(m) == 0 {
+		return ParsedURL{}, nil
+	}
+	result, err := parseId(URLSpace, m[1])
+	if err != nil {
+		return ParsedURL{}, err
+	}
+	sub := strings.Trim(m[2], "/")
+	switch {
+	case len(sub) == 0:
+	case sub == "favlist":
+		result.Tab = "favlist"
+		result.SubId, _ = strconv.Atoi(query.Get("fid"))
+	case sub == "channel/seriesdetail":
+		result.Tab = "series"
+		result.SubId, _ = strconv.Atoi(query.Get("sid"))
+	case sub == "channel/collectiondetail":
+		result.Tab = "collection"
+		result.SubId, _ = strconv.Atoi(query.Get("sid"))
+	case regListsPath.MatchString(sub):
+		// 新版空间的列表和合集都是 lists/<sid>，通过 type 区分
+		result.Tab = "collection"
+		if query.Get("type") == "series" {
+			result.Tab = "series"
+		}
+		result.SubId, _ = strconv.Atoi(regListsPath.FindStringSubmatch(sub)[1])
+	default:
+		result.Tab, _, _ = strings.Cut(sub, "/")
+	}
+	return result, nil
+}
+
+// parseIdToken 解析不带链接的编号，例如 av170001、BV17x411w7KC、ep123
+func parseIdToken(s string) (ParsedURL, error) {
+	m := regIdToken.FindStringSubmatch(s)
+	if len(m) == 0 {
+		id, err := parseVideoNumber(s)
+		if err != nil {
+			return ParsedURL{}, err
+		}
+		return ParsedURL{Kind: URLVideo, Video: id}, nil
+	}
+	kind := map[string]URLKind{
+		"ep": URLBangumiEpisode,
+		"ss": URLBangumiSeason,
+		"cv": URLArticle,
+		"rl": URLReadList,
+		"ml": URLFavFolder,
+		"au": URLAudio,
+	}[strings.ToLower(m[1])]
+	return parseId(kind, m[2])
+}
+
+func matchId(kind URLKind, reg *regexp.Regexp, path string) (ParsedURL, error) {
+	m := reg.FindStringSubmatch(path)
+	if len(m) == 0 {
+		return ParsedURL{}, nil
+	}
+	return parseId(kind, m[1])
+}
+
+func parseId(kind URLKind, s string) (ParsedURL, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return ParsedURL{}, errors.Wrapf(ErrInvalidParam, "%s 的id不正确: %s", kind, s)
+	}
+	return ParsedURL{Kind: kind, Id: id}, nil
+}
+
+func isShortUrlHost(host string) bool {
+	return host == "b23.tv" || host == "bili2233.cn"
+}
+
+// ResolveURL 与 ParseURL 相同，另外还支持 b23.tv 的短链接，会发起一次请求取得短链接跳转的地址
+func (c *Client) ResolveURL(rawUrl string) (ParsedURL, error) {
+	s := strings.TrimSpace(rawUrl)
+	u, err := url.Parse(s)
+	if err != nil || !isShortUrlHost(strings.ToLower(u.Hostname())) {
+		return ParseURL(s)
+	}
+	location, err := c.shortUrlLocation(s)
+	if err != nil {
+		return ParsedURL{}, err
+	}
+	return ParseURL(location)
+}
+
+// shortUrlLocation 返回短链接跳转的地址
+func (c *Client) shortUrlLocation(shortUrl string) (location string, err error) {
+	err = c.call(http.MethodGet, shortUrl, func(c *Client) error {
+		r := c.newRequest(shortUrl)
+		// 不跟随跳转，resty 会在返回响应的同时返回错误
+		resp, err := r.Get(c.resolveUrl(shortUrl))
+		if resp == nil || resp.RawResponse == nil {
+			return errors.WithStack(err)
+		}
+		recordResponse(r.Context(), r.QueryParam, resp.StatusCode())
+		if resp.StatusCode() != http.StatusFound {
+			return errors.Wrap(StatusError{StatusCode: resp.StatusCode()}, "解析短链接失败")
+		}
+		location = resp.Header().Get("Location")
+		return nil
+	})
+	return
+}
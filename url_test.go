@@ -0,0 +1,101 @@
+package bilibili_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestParseURL(t *testing.T) {
+	video := bilibili.VideoID{Aid: 170001, Bvid: "BV17x411w7KC"}
+	for s, expected := range map[string]bilibili.ParsedURL{
+		"https://www.bilibili.com/video/BV17x411w7KC?p=2&t=90.5": {Kind: bilibili.URLVideo, Video: bilibili.VideoID{Aid: 170001, Bvid: "BV17x411w7KC", Page: 2}, Time: 90500 * time.Millisecond},
+		"https://m.bilibili.com/video/av170001?t=1m30s":          {Kind: bilibili.URLVideo, Video: video, Time: 90 * time.Second},
+		"av170001": {Kind: bilibili.URLVideo, Video: video},
+		"https://www.bilibili.com/bangumi/play/ep1": {Kind: bilibili.URLBangumiEpisode, Id: 1},
+		"https://www.bilibili.com/bangumi/play/ss2": {Kind: bilibili.URLBangumiSeason, Id: 2},
+		"ep3":                               {Kind: bilibili.URLBangumiEpisode, Id: 3},
+		"https://www.bilibili.com/read/cv4": {Kind: bilibili.URLArticle, Id: 4},
+		"https://www.bilibili.com/read/mobile?id=5":                   {Kind: bilibili.URLArticle, Id: 5},
+		"https://www.bilibili.com/read/readlist/rl6":                  {Kind: bilibili.URLReadList, Id: 6},
+		"https://space.bilibili.com/7":                                {Kind: bilibili.URLSpace, Id: 7},
+		"https://space.bilibili.com/7/video":                          {Kind: bilibili.URLSpace, Id: 7, Tab: "video"},
+		"https://space.bilibili.com/7/favlist?fid=8":                  {Kind: bilibili.URLSpace, Id: 7, Tab: "favlist", SubId: 8},
+		"https://space.bilibili.com/7/channel/seriesdetail?sid=9":     {Kind: bilibili.URLSpace, Id: 7, Tab: "series", SubId: 9},
+		"https://space.bilibili.com/7/channel/collectiondetail?sid=9": {Kind: bilibili.URLSpace, Id: 7, Tab: "collection", SubId: 9},
+		"https://space.bilibili.com/7/lists/9?type=series":            {Kind: bilibili.URLSpace, Id: 7, Tab: "series", SubId: 9},
+		"https://m.bilibili.com/space/7":                              {Kind: bilibili.URLSpace, Id: 7},
+		"https://t.bilibili.com/1010101010101010101":                  {Kind: bilibili.URLDynamic, Id: 1010101010101010101},
+		"https://www.bilibili.com/opus/11":                            {Kind: bilibili.URLDynamic, Id: 11},
+		"https://www.bilibili.com/medialist/detail/ml12":              {Kind: bilibili.URLFavFolder, Id: 12},
+		"https://www.bilibili.com/audio/au13":                         {Kind: bilibili.URLAudio, Id: 13},
+		"https://live.bilibili.com/14?spm_id_from=333":                {Kind: bilibili.URLLive, Id: 14},
+		"live.bilibili.com/h5/15":                                     {Kind: bilibili.URLLive, Id: 15},
+	} {
+		result, err := bilibili.ParseURL(s)
+		if err != nil {
+			t.Fatal(s, " ", err)
+		}
+		if result != expected {
+			t.Fatal(s, " expected ", expected, ", got ", result)
+		}
+	}
+
+	for _, s := range []string{
+		"https://example.com/video/BV17x411w7KC",
+		"https://www.bilibili.com/anime/",
+		"https://b23.tv/abcdef",
+		"https://www.bilibili.com/video/BV17x411w7KC?t=abc",
+		"https://live.bilibili.com/p/eden/area-tags",
+		"xx123",
+	} {
+		if _, err := bilibili.ParseURL(s); !errors.Is(err, bilibili.ErrInvalidParam) {
+			t.Fatal(s, " expected bilibili.ErrInvalidParam, got ", err)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	server.HandleRaw("/abcdef", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://www.bilibili.com/video/BV1xx411c7mD?p=2", http.StatusFound)
+	})
+	server.HandleRaw("/live", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://live.bilibili.com/14", http.StatusFound)
+	})
+	server.HandleRaw("/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/", http.StatusFound)
+	})
+
+	var calls []bilibili.Call
+	c := server.NewClient().SetHost("https://b23.tv", server.URL).Use(func(next bilibili.Invoker) bilibili.Invoker {
+		return func(call *bilibili.Call) error {
+			err := next(call)
+			calls = append(calls, *call)
+			return err
+		}
+	})
+	result, err := c.ResolveURL("https://b23.tv/abcdef")
+	if err != nil || result.Kind != bilibili.URLVideo || result.Video.Bvid != "BV1xx411c7mD" {
+		t.Fatal("ResolveURL result not correct ", result, err)
+	}
+	if len(calls) != 1 || calls[0].Url != "https://b23.tv/abcdef" || calls[0].StatusCode != http.StatusFound {
+		t.Fatal("short url request should go through middlewares ", calls)
+	}
+
+	if _, err = c.ResolveURL("https://b23.tv/notfound"); !errors.As(err, &bilibili.StatusError{}) {
+		t.Fatal("expected StatusError, got ", err)
+	}
+
+	if kind, id, err := c.UnwrapShortUrl("https://b23.tv/live"); err != nil || kind != "live" || id != 14 {
+		t.Fatal("UnwrapShortUrl result not correct ", kind, id, err)
+	}
+	if _, _, err = c.UnwrapShortUrl("https://b23.tv/other"); !errors.Is(err, bilibili.ErrInvalidParam) {
+		t.Fatal("expected ErrInvalidParam, got ", err)
+	}
+}
@@ -73,7 +73,7 @@ func (c *Client) GetUserVideos(param GetUserVideosParam) (*UserVideos, error) {
 		method = resty.MethodGet
 		url    = "https://api.bilibili.com/x/space/wbi/arc/search"
 	)
-	return execute[*UserVideos](c, method, url, param, fillWbiHandler(c.wbi, c.GetCookies()))
+	return executeWbi[*UserVideos](c, method, url, param)
 }
 
 type GetUserSpaceDetailParam struct {
@@ -232,7 +232,7 @@ func (c *Client) GetUserSpaceDetail(param GetUserSpaceDetailParam) (*UserSpaceDe
 		method = resty.MethodGet
 		url    = "https://api.bilibili.com/x/space/wbi/acc/info"
 	)
-	return execute[*UserSpaceDetail](c, method, url, param, fillWbiHandler(c.wbi, c.GetCookies()))
+	return executeWbi[*UserSpaceDetail](c, method, url, param)
 }
 
 type GetUserCardParam struct {
@@ -715,7 +715,7 @@ func (c *Client) GetUserRelation2(param GetUserRelation2Param) (*GetUserRelation
 		method = resty.MethodGet
 		url    = "https://api.bilibili.com/x/space/wbi/acc/relation"
 	)
-	return execute[*GetUserRelation2Result](c, method, url, param, fillWbiHandler(c.wbi, c.GetCookies()))
+	return executeWbi[*GetUserRelation2Result](c, method, url, param)
 }
 
 type BatchGetUserRelationParam struct {
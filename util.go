@@ -1,10 +1,13 @@
 package bilibili
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
+	"bytes"
+	"encoding"
+	"io"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -20,8 +23,11 @@ type paramHandler func(*resty.Request) error
 func fillCsrf(c *Client) paramHandler {
 	return func(r *resty.Request) error {
 		csrf := c.getCookie("bili_jct")
+		if len(csrf) == 0 && c.tryAutoRefresh() {
+			csrf = c.getCookie("bili_jct")
+		}
 		if len(csrf) == 0 {
-			return errors.New("B站登录过期")
+			return errors.WithStack(ErrNotLoggedIn)
 		}
 		r.SetQueryParam("csrf", csrf)
 		r.SetQueryParam("csrf_token", csrf)
@@ -36,47 +42,100 @@ func fillParam(key, value string) paramHandler {
 	}
 }
 
-func fillWbiHandler(wbi *WBI, cookies []*http.Cookie) func(*resty.Request) error {
+func fillWbiHandler(wbi *WBI) paramHandler {
 	return func(r *resty.Request) error {
-		newQuery, err := wbi.SignQuery(r.QueryParam, time.Now())
+		newQuery, err := wbi.SignQueryContext(r.Context(), r.QueryParam, time.Now())
 		if err != nil {
 			return err
 		}
 
 		r.QueryParam = newQuery
-		r.Cookies = cookies
 		r.Header.Del("Referer")
 		return nil
 	}
 }
 
+// executeWbi 发起需要 WBI 签名的请求。
+//
+// 如果B站返回了可能是签名错误的错误码，会立即刷新 wbi keys，如果 wbi keys 确实已经更换，则重新签名并请求一次。
+func executeWbi[Out any](c *Client, method, url string, in any, handlers ...paramHandler) (out Out, err error) {
+	handlers = append(handlers, fillWbiHandler(c.wbi))
+	out, err = execute[Out](c, method, url, in, handlers...)
+	if !isWbiSignError(err) {
+		return
+	}
+	oldKeys, _ := c.wbi.loadKeys()
+	if c.wbi.Refresh(c.Context()) != nil {
+		return
+	}
+	if newKeys, _ := c.wbi.loadKeys(); newKeys.ImgKey == oldKeys.ImgKey && newKeys.SubKey == oldKeys.SubKey {
+		return
+	}
+	return execute[Out](c, method, url, in, handlers...)
+}
+
+// isWbiSignError 判断 WBI 签名的接口返回的错误是否可能是签名错误：-403（访问权限不足）或 -352（风控校验失败）
+func isWbiSignError(err error) bool {
+	return errors.Is(err, ErrNoPermission) || errors.Is(err, ErrRiskControl)
+}
+
 // execute 发起请求
+//
+// 请求使用 c.Context() 作为 context，handlers 中可以通过 r.Context() 取得它。
+// 设置了响应缓存的接口先查找缓存，参见 Client.SetResponseCache。
 func execute[Out any](c *Client, method, url string, in any, handlers ...paramHandler) (out Out, err error) {
-	r := c.resty.R()
-	if err = withParams(r, in); err != nil {
+	if key, ttl, ok := c.cacheKey(method, url, in); ok {
+		err = c.callWith(method, url, func(c *Client, call *Call) error {
+			body, err := c.fetchCached(call, key, ttl, func(c *Client) ([]byte, error) {
+				return c.send(method, url, in, handlers...)
+			})
+			if err != nil {
+				return err
+			}
+			out, err = decodeResponse[Out](c, body)
+			return err
+		})
 		return
 	}
+	err = c.call(method, url, func(c *Client) error {
+		out, err = doExecute[Out](c, method, url, in, handlers...)
+		return err
+	})
+	return
+}
+
+// doExecute 发起一次请求，不进行重试
+func doExecute[Out any](c *Client, method, url string, in any, handlers ...paramHandler) (out Out, err error) {
+	body, err := c.send(method, url, in, handlers...)
+	if err != nil {
+		return out, err
+	}
+	return decodeResponse[Out](c, body)
+}
+
+// send 发起一次请求，检查状态码并保存cookies，返回响应的内容
+func (c *Client) send(method, url string, in any, handlers ...paramHandler) ([]byte, error) {
+	r := c.resty.R().SetContext(c.Context())
+	if err := withParams(r, in); err != nil {
+		return nil, err
+	}
 	for _, handler := range handlers {
-		if err = handler(r); err != nil {
-			return
+		if err := handler(r); err != nil {
+			return nil, err
 		}
 	}
-	resp, err := r.Execute(method, url)
+	// 在 handlers 之后才带上cookies，因为 handler 可能会刷新cookies，也可能自行设置 r.Cookies
+	c.attachCookies(r, url)
+	resp, err := r.Execute(method, c.resolveUrl(url))
 	if err != nil {
-		return out, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
+	recordResponse(r.Context(), r.QueryParam, resp.StatusCode())
 	if resp.StatusCode() != 200 {
-		return out, errors.Errorf("status code: %d", resp.StatusCode())
-	}
-	c.SetCookies(resp.Cookies())
-	var cr commonResp[Out]
-	if err = json.Unmarshal(resp.Body(), &cr); err != nil {
-		return out, errors.WithStack(err)
+		return nil, errors.WithStack(StatusError{StatusCode: resp.StatusCode()})
 	}
-	if cr.Code != 0 {
-		return out, errors.WithStack(Error{Code: cr.Code, Message: cr.Message})
-	}
-	return cr.Data, errors.WithStack(err)
+	c.updateCookies(url, resp.Cookies())
+	return resp.Body(), nil
 }
 
 type ContentType string
@@ -87,9 +146,17 @@ const (
 	ContentTypeForm ContentType = "multipart/form-data"
 )
 
-// execute 发起请求
+// RawExecute 发起请求，返回未经解析的 data 字段。请求使用 c.Context() 作为 context。
 func RawExecute(c *Client, method, url string, contentType ContentType, urlParam map[string]string, bodyParam map[string]any) (out *simplejson.Json, err error) {
-	r := c.resty.R()
+	err = c.call(method, url, func(c *Client) error {
+		out, err = doRawExecute(c, method, url, contentType, urlParam, bodyParam)
+		return err
+	})
+	return
+}
+
+func doRawExecute(c *Client, method, url string, contentType ContentType, urlParam map[string]string, bodyParam map[string]any) (out *simplejson.Json, err error) {
+	r := c.newRequest(url)
 	r.SetHeader("Content-Type", string(contentType))
 
 	for k, v := range urlParam {
@@ -99,14 +166,15 @@ func RawExecute(c *Client, method, url string, contentType ContentType, urlParam
 		r.SetBody(bodyParam)
 	}
 
-	resp, err := r.Execute(method, url)
+	resp, err := r.Execute(method, c.resolveUrl(url))
 	if err != nil {
 		return out, errors.WithStack(err)
 	}
+	recordResponse(r.Context(), r.QueryParam, resp.StatusCode())
 	if resp.StatusCode() != 200 {
-		return out, errors.Errorf("status code: %d", resp.StatusCode())
+		return out, errors.WithStack(StatusError{StatusCode: resp.StatusCode()})
 	}
-	c.SetCookies(resp.Cookies())
+	c.updateCookies(url, resp.Cookies())
 	body, err := simplejson.NewJson(resp.Body())
 	if err != nil {
 		return out, errors.WithStack(err)
@@ -125,7 +193,22 @@ type commonResp[T any] struct {
 	Data    T      `json:"data"`
 }
 
+// withParams 把 in 的字段设置为请求的参数，in 必须是结构体或者结构体指针。
+//
+// 字段的 request 标签决定参数的位置：query（url 参数，默认）、form（application/x-www-form-urlencoded 正文）、
+// json（application/json 正文）、form-data（multipart/form-data 正文，io.Reader 类型的字段会作为文件上传，内容会读入内存，以便重试时重新发送）。
+// url 参数可以和任意一种正文参数同时使用，但正文只能使用一种格式。
+//
+// 其它选项：field=参数名、omitempty、default=默认值、sep=切片的分隔符（默认为逗号）、filename=上传的文件名。
+//
+// 嵌入的结构体的字段视为外层的字段。除 json 以外，结构体和 map 会被展开为 msg[sender_uid] 形式的参数，
+// time.Time 转换为秒级时间戳，time.Duration 转换为秒数，实现了 encoding.TextMarshaler 的类型使用 MarshalText 的结果。
 func withParams(r *resty.Request, in any) error {
+	return encodeParams(r, in, false)
+}
+
+// encodeParams 即 withParams，skipFiles 为 true 时忽略要上传的文件，不读取它们的内容
+func encodeParams(r *resty.Request, in any, skipFiles bool) error {
 	if in == nil {
 		return nil
 	}
@@ -139,49 +222,75 @@ func withParams(r *resty.Request, in any) error {
 		if inValue.IsNil() {
 			return nil
 		}
-		inType = inType.Elem()
 		inValue = inValue.Elem()
+		if inValue.Kind() != reflect.Struct {
+			return errors.WithStack(ErrInvalidParam)
+		}
 	case reflect.Struct:
 	default:
-		return errors.New("参数类型错误")
+		return errors.WithStack(ErrInvalidParam)
 	}
 
-	bodyMap := make(map[string]any, 4)
-	contentType := ""
-	for i := 0; i < inType.NumField(); i++ {
-		fieldType := inType.Field(i)
-		if !fieldType.IsExported() {
-			continue
-		}
-		fieldValue := inValue.Field(i)
-		tValue := fieldType.Tag.Get("request")
+	if err := validateParams(inValue); err != nil {
+		return errors.WithStack(err)
+	}
 
-		if tValue == "-" {
-			continue
-		}
+	e := &paramEncoder{
+		r:         r,
+		jsonBody:  make(map[string]any, 4),
+		formBody:  make(map[string]string, 4),
+		multipart: make(map[string]string, 4),
+		skipFiles: skipFiles,
+	}
+	e.encodeStruct(inValue)
+	if e.err != nil {
+		return e.err
+	}
 
-		// 获取字段名
-		var fieldName string
+	contentType := "application/x-www-form-urlencoded"
+	bodies := 0
+	if len(e.jsonBody) > 0 {
+		contentType = "application/json"
+		r.SetBody(e.jsonBody)
+		bodies++
+	}
+	if len(e.formBody) > 0 {
+		r.SetFormData(e.formBody)
+		bodies++
+	}
+	if len(e.multipart) > 0 || e.hasFile {
+		// resty 发送时会把 Content-Type 替换为带有 boundary 的值
+		contentType = "multipart/form-data"
+		r.SetMultipartFormData(e.multipart)
+		bodies++
+	}
+	if bodies > 1 {
+		return errors.Wrap(ErrInvalidParam, "正文参数只能使用一种格式")
+	}
+	r.SetHeader("Content-Type", contentType)
+	return nil
+}
 
-		tagMap := parseTag(tValue)
-		if name, ok := tagMap["field"]; ok {
-			fieldName = name
-		} else if jsonValue := fieldType.Tag.Get("json"); jsonValue != "" && jsonValue != "-" {
-			if index := strings.Index(jsonValue, ","); index != -1 {
-				jsonValue = jsonValue[:index]
-			}
-			fieldName = jsonValue
-		} else {
-			fieldName = toSnakeCase(fieldType.Name)
-		}
+// paramEncoder 收集 withParams 中各个位置的参数
+type paramEncoder struct {
+	r         *resty.Request
+	jsonBody  map[string]any
+	formBody  map[string]string
+	multipart map[string]string
+	hasFile   bool
+	skipFiles bool
+	err       error
+}
 
+func (e *paramEncoder) encodeStruct(v reflect.Value) {
+	walkParams(v, func(fieldName string, tagMap map[string]string, fieldValue reflect.Value) {
 		var realVal any
 		if !fieldValue.IsZero() {
 			realVal = fieldValue.Interface()
 		} else {
 			// 设置了 omitempty 代表不传
 			if _, ok := tagMap["omitempty"]; ok {
-				continue
+				return
 			}
 			// 设置了 default 代表使用默认值
 			if v, ok := tagMap["default"]; ok {
@@ -192,42 +301,210 @@ func withParams(r *resty.Request, in any) error {
 			}
 		}
 
-		contentType = "application/x-www-form-urlencoded"
-		for name := range tagMap {
-			switch name {
-			case "query":
-				contentType = "application/x-www-form-urlencoded"
-			case "json":
-				contentType = "application/json"
-			case "form-data":
-				contentType = "multipart/form-data"
+		sep, ok := tagMap["sep"]
+		if !ok {
+			sep = ","
+		}
+		switch {
+		case hasKey(tagMap, "json"):
+			e.jsonBody[fieldName] = realVal
+		case hasKey(tagMap, "form-data"):
+			if reader, ok := realVal.(io.Reader); ok {
+				if e.skipFiles {
+					return
+				}
+				data, err := e.readFile(fieldName, reader)
+				if err != nil {
+					e.err = err
+					return
+				}
+				e.r.SetFileReader(fieldName, fileName(reader, tagMap["filename"], fieldName), bytes.NewReader(data))
+				e.hasFile = true
+				return
 			}
+			flattenParam(fieldName, realVal, sep, func(k, v string) { e.multipart[k] = v })
+		case hasKey(tagMap, "form"):
+			flattenParam(fieldName, realVal, sep, func(k, v string) { e.formBody[k] = v })
+		default:
+			flattenParam(fieldName, realVal, sep, func(k, v string) { e.r.SetQueryParam(k, v) })
 		}
-		if contentType == "application/x-www-form-urlencoded" {
-			_, ok1 := tagMap["json"]
-			_, ok2 := tagMap["form-data"]
-			if !ok1 && !ok2 {
-				// 对query类型的字段进行特殊处理
-				if fieldType.Type.Kind() == reflect.Slice {
-					strSlice := make([]string, 0, 4)
-					for i := 0; i < fieldValue.Len(); i++ {
-						strSlice = append(strSlice, cast.ToString(fieldValue.Index(i).Interface()))
-					}
-					realVal = strings.Join(strSlice, ",")
+	})
+}
+
+// walkParams 遍历结构体 v 中会成为参数的字段，没有指定参数名的嵌入结构体，它的字段视为外层的字段
+func walkParams(v reflect.Value, f func(name string, tagMap map[string]string, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i)
+		fieldValue := v.Field(i)
+		tValue := fieldType.Tag.Get("request")
+		if tValue == "-" {
+			continue
+		}
+		tagMap := parseTag(tValue)
+		if fieldType.Anonymous && len(paramName(fieldType, tagMap)) == 0 {
+			embedded := fieldValue
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
 				}
+				embedded = embedded.Elem()
 			}
-			r.SetQueryParam(fieldName, cast.ToString(realVal))
-		} else {
-			bodyMap[fieldName] = realVal
+			if embedded.Kind() == reflect.Struct {
+				walkParams(embedded, f)
+				continue
+			}
+		}
+		if !fieldType.IsExported() {
+			continue
 		}
+		name := paramName(fieldType, tagMap)
+		if len(name) == 0 {
+			name = toSnakeCase(fieldType.Name)
+		}
+		f(name, tagMap, fieldValue)
 	}
+}
 
-	r.SetHeader("Content-Type", contentType)
-	if len(bodyMap) > 0 {
-		r.SetBody(bodyMap)
+// readFile 读取上传的文件。reader 只能读取一次，所以内容会保存在请求所属的 Call 中，重试时重新发送同样的内容
+func (e *paramEncoder) readFile(name string, reader io.Reader) ([]byte, error) {
+	call := callFromContext(e.r.Context())
+	if data, ok := call.uploadedFile(name); ok {
+		return data, nil
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if call != nil {
+		if call.files == nil {
+			call.files = make(map[string][]byte, 1)
+		}
+		call.files[name] = data
 	}
+	return data, nil
+}
 
-	return nil
+// paramName 返回 field 标签或者 json 标签中指定的参数名，没有指定时返回空字符串
+func paramName(fieldType reflect.StructField, tagMap map[string]string) string {
+	if name, ok := tagMap["field"]; ok {
+		return name
+	}
+	if jsonValue := fieldType.Tag.Get("json"); jsonValue != "" && jsonValue != "-" {
+		if index := strings.Index(jsonValue, ","); index != -1 {
+			jsonValue = jsonValue[:index]
+		}
+		return jsonValue
+	}
+	return ""
+}
+
+func hasKey(tagMap map[string]string, key string) bool {
+	_, ok := tagMap[key]
+	return ok
+}
+
+// fileName 返回上传文件的文件名：优先使用标签中指定的，其次是 reader 的 Name()（例如 *os.File），最后是参数名
+func fileName(reader io.Reader, tagName, fieldName string) string {
+	if len(tagName) > 0 {
+		return tagName
+	}
+	if named, ok := reader.(interface{ Name() string }); ok && len(named.Name()) > 0 {
+		return filepath.Base(named.Name())
+	}
+	return fieldName
+}
+
+// flattenParam 把 value 转换为字符串参数：结构体和 map 展开为 name[key] 形式，切片使用 sep 连接
+func flattenParam(name string, value any, sep string, set func(key, value string)) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			set(name, "")
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		set(name, "")
+		return
+	}
+	if s, ok := formatParam(v.Interface()); ok {
+		set(name, s)
+		return
+	}
+	if v.CanAddr() {
+		// 指针接收者实现的 encoding.TextMarshaler
+		if s, ok := formatParam(v.Addr().Interface()); ok {
+			set(name, s)
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			fieldType := t.Field(i)
+			if !fieldType.IsExported() || fieldType.Tag.Get("request") == "-" {
+				continue
+			}
+			tagMap := parseTag(fieldType.Tag.Get("request"))
+			fieldName := paramName(fieldType, tagMap)
+			if len(fieldName) == 0 {
+				fieldName = toSnakeCase(fieldType.Name)
+			}
+			fieldValue := v.Field(i)
+			if fieldValue.IsZero() && (hasKey(tagMap, "omitempty") || strings.Contains(fieldType.Tag.Get("json"), ",omitempty")) {
+				continue
+			}
+			flattenParam(name+"["+fieldName+"]", fieldValue.Interface(), sep, set)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return cast.ToString(keys[i].Interface()) < cast.ToString(keys[j].Interface())
+		})
+		for _, key := range keys {
+			flattenParam(name+"["+cast.ToString(key.Interface())+"]", v.MapIndex(key).Interface(), sep, set)
+		}
+	case reflect.Slice, reflect.Array:
+		strSlice := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i).Interface()
+			if s, ok := formatParam(elem); ok {
+				strSlice = append(strSlice, s)
+			} else {
+				strSlice = append(strSlice, cast.ToString(elem))
+			}
+		}
+		set(name, strings.Join(strSlice, sep))
+	default:
+		set(name, cast.ToString(v.Interface()))
+	}
+}
+
+// formatParam 转换有特殊格式的类型，不是这些类型时返回 false
+func formatParam(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case time.Time:
+		if v.IsZero() {
+			return "0", true
+		}
+		return strconv.FormatInt(v.Unix(), 10), true
+	case time.Duration:
+		return strconv.FormatInt(int64(v/time.Second), 10), true
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return "", false
+		}
+		return string(text), true
+	}
+	return "", false
 }
 
 func parseTag(tag string) map[string]string {
@@ -263,12 +540,3 @@ func toSnakeCase(s string) string {
 
 	return result.String()
 }
-
-type Error struct {
-	Code    int
-	Message string
-}
-
-func (e Error) Error() string {
-	return fmt.Sprintf("错误码: %d, 错误信息: %s", e.Code, e.Message)
-}
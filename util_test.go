@@ -1,9 +1,18 @@
 package bilibili
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
 )
 
 func TestQuery(t *testing.T) {
@@ -201,18 +210,29 @@ func TestFormData(t *testing.T) {
 		t.Fatal("withParams content type not correct ", r.Header.Get("Content-Type"))
 	}
 
-	body := r.Body.(map[string]any)
-	if len(body) != 9 ||
-		body["test_a"] != "" ||
-		body["tb"] != "1" ||
-		body["test_e"] != "1" ||
-		body["test_f"] != &f ||
-		body["test_g"] != "1" ||
-		body["test_i"] != &i ||
-		body["test_j"] != (*int)(nil) ||
-		body["test_k"] != 0 ||
-		body["testM"] != "" {
-		t.Fatal("withParams body result not correct ", r.Body)
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+		}
+		form = req.MultipartForm.Value
+	}))
+	defer server.Close()
+	if _, err = r.Post(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(form) != 9 ||
+		form.Get("test_a") != "" ||
+		form.Get("tb") != "1" ||
+		form.Get("test_e") != "1" ||
+		form.Get("test_f") != "10" ||
+		form.Get("test_g") != "1" ||
+		form.Get("test_i") != "0" ||
+		form.Get("test_j") != "" ||
+		form.Get("test_k") != "0" ||
+		form.Get("testM") != "" {
+		t.Fatal("withParams form data result not correct ", form)
 	}
 }
 
@@ -273,3 +293,107 @@ func TestWithParamsNil(t *testing.T) {
 		t.Fatal("withParams body result not correct ", r.Body)
 	}
 }
+
+type testLevel int
+
+func (l testLevel) MarshalText() ([]byte, error) {
+	return []byte("lv" + strconv.Itoa(int(l))), nil
+}
+
+func TestWithParamsNested(t *testing.T) {
+	type Msg struct {
+		SenderUid int    `json:"sender_uid"`
+		Content   string `json:"content,omitempty"`
+	}
+	type Base struct {
+		Mid int `json:"mid"`
+	}
+	type Test struct {
+		Base
+		Msg      Msg               `json:"msg"`
+		Extra    map[string]int    `json:"extra"`
+		Ts       time.Time         `json:"ts"`
+		Duration time.Duration     `json:"duration"`
+		Level    testLevel         `json:"level"`
+		Tags     []string          `json:"tags" request:"query,sep=;"`
+		Data     map[string]string `json:"data" request:"json"`
+	}
+
+	params := Test{
+		Base:     Base{Mid: 2},
+		Msg:      Msg{SenderUid: 1},
+		Extra:    map[string]int{"b": 2, "a": 1},
+		Ts:       time.Unix(1700000000, 0),
+		Duration: 90 * time.Second,
+		Level:    6,
+		Tags:     []string{"x", "y"},
+		Data:     map[string]string{"k": "v"},
+	}
+	r := resty.New().R()
+	if err := withParams(r, params); err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"mid":             {"2"},
+		"msg[sender_uid]": {"1"},
+		"extra[a]":        {"1"},
+		"extra[b]":        {"2"},
+		"ts":              {"1700000000"},
+		"duration":        {"90"},
+		"level":           {"lv6"},
+		"tags":            {"x;y"},
+	}
+	if !reflect.DeepEqual(r.QueryParam, expected) {
+		t.Fatal("withParams query result not correct ", r.QueryParam)
+	}
+	if r.Header.Get("Content-Type") != "application/json" || r.Body.(map[string]any)["data"] == nil {
+		t.Fatal("json body should be kept with query params ", r.Header, r.Body)
+	}
+
+	type Mixed struct {
+		A string `request:"json"`
+		B string `request:"form"`
+	}
+	if err := withParams(resty.New().R(), Mixed{}); !errors.Is(err, ErrInvalidParam) {
+		t.Fatal("json and form body should not be mixed, got ", err)
+	}
+}
+
+func TestWithParamsFile(t *testing.T) {
+	type Test struct {
+		File     io.Reader `json:"file_up" request:"form-data,filename=a.png"`
+		Category string    `json:"category"`
+		Biz      string    `json:"biz" request:"form-data"`
+	}
+
+	var fileName, content, biz, category string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		file, header, err := req.FormFile("file_up")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := io.ReadAll(file)
+		fileName, content = header.Filename, string(b)
+		biz, category = req.FormValue("biz"), req.URL.Query().Get("category")
+	}))
+	defer server.Close()
+
+	r := resty.New().R()
+	if err := withParams(r, Test{File: strings.NewReader("png"), Category: "daily", Biz: "new_dyn"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Post(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if fileName != "a.png" || content != "png" || biz != "new_dyn" || category != "daily" {
+		t.Fatal("multipart request not correct ", fileName, content, biz, category)
+	}
+
+	// 计算缓存 key 时不能读取文件
+	c := New().SetResponseCache(NewResponseCache(NewMemoryCache(10, 0)).SetEndpointTTL("/upload", time.Minute))
+	file := strings.NewReader("png")
+	if _, _, ok := c.cacheKey(http.MethodGet, server.URL+"/upload", Test{File: file, Category: "daily"}); !ok || file.Len() != 3 {
+		t.Fatal("file should not be read by cacheKey ", ok, file.Len())
+	}
+}
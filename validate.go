@@ -0,0 +1,131 @@
+package bilibili
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// FieldError 是一个没有通过校验的参数
+type FieldError struct {
+	Field   string // 参数名，oneof 校验失败时是用 | 连接的一组参数名
+	Rule    string // 没有通过的规则：required、oneof、min、max、enum
+	Message string // 错误说明
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError 表示传入的参数没有通过 request 标签中的校验规则，Fields 中是全部不符合要求的参数。
+//
+// 可以使用 errors.Is(err, ErrInvalidParam) 判断，也可以使用 errors.As 取得具体的参数。
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.String())
+	}
+	return fmt.Sprintf("参数校验失败: %s", strings.Join(fields, "; "))
+}
+
+// Is 支持 errors.Is(err, ErrInvalidParam)
+func (e ValidationError) Is(target error) bool {
+	return target == ErrInvalidParam
+}
+
+// validateParams 按照 request 标签中的规则校验参数，v 必须是结构体：
+//
+//   - required：不能为零值
+//   - oneof=aid|bvid：这一组参数中至少要填写一个，组中的每个字段都要写上相同的规则
+//   - min=1、max=30：数字的取值范围，字符串、切片和 map 的长度范围
+//   - enum=1|2：只能取这些值中的一个
+//
+// 零值且设置了 omitempty、default 或 required 的参数不检查 min、max 和 enum。
+func validateParams(v reflect.Value) error {
+	var (
+		fields []FieldError
+		groups = make(map[string]bool) // oneof 的参数组中是否有非零值的参数
+		order  []string
+	)
+	walkParams(v, func(name string, tagMap map[string]string, value reflect.Value) {
+		zero := value.IsZero()
+		if group, ok := tagMap["oneof"]; ok {
+			if _, seen := groups[group]; !seen {
+				order = append(order, group)
+			}
+			groups[group] = groups[group] || !zero
+		}
+		if zero {
+			if hasKey(tagMap, "required") {
+				fields = append(fields, FieldError{Field: name, Rule: "required", Message: "不能为空"})
+				return
+			}
+			if hasKey(tagMap, "omitempty") || hasKey(tagMap, "default") {
+				return
+			}
+		}
+		if min, ok := tagMap["min"]; ok {
+			if n, ok := paramSize(value); ok && n < cast.ToFloat64(min) {
+				fields = append(fields, FieldError{Field: name, Rule: "min", Message: "不能小于 " + min})
+			}
+		}
+		if max, ok := tagMap["max"]; ok {
+			if n, ok := paramSize(value); ok && n > cast.ToFloat64(max) {
+				fields = append(fields, FieldError{Field: name, Rule: "max", Message: "不能大于 " + max})
+			}
+		}
+		if enum, ok := tagMap["enum"]; ok {
+			s := cast.ToString(value.Interface())
+			if !contains(strings.Split(enum, "|"), s) {
+				fields = append(fields, FieldError{Field: name, Rule: "enum", Message: "只能是 " + strings.ReplaceAll(enum, "|", "、") + " 中的一个"})
+			}
+		}
+	})
+	for _, group := range order {
+		if !groups[group] {
+			fields = append(fields, FieldError{Field: group, Rule: "oneof", Message: "至少需要填写一个"})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return ValidationError{Fields: fields}
+}
+
+// paramSize 返回 min 和 max 比较的值：数字的值，字符串、切片和 map 的长度
+func paramSize(v reflect.Value) (float64, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
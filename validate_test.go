@@ -0,0 +1,52 @@
+package bilibili
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+func TestValidateParams(t *testing.T) {
+	var e ValidationError
+	err := withParams(resty.New().R(), VideoParam{})
+	if !errors.Is(err, ErrInvalidParam) || !errors.As(err, &e) {
+		t.Fatal("expected ValidationError, got ", err)
+	}
+	if !reflect.DeepEqual(e.Fields, []FieldError{{Field: "aid|bvid", Rule: "oneof", Message: "至少需要填写一个"}}) {
+		t.Fatal("unexpected fields ", e.Fields)
+	}
+	if err = withParams(resty.New().R(), VideoParam{Bvid: "BV1xx411c7mD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 零值且 omitempty 的参数不检查 max
+	if err = withParams(resty.New().R(), GetHistoryParam{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = withParams(resty.New().R(), GetHistoryParam{Ps: 31}); !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Rule != "max" {
+		t.Fatal("expected max error, got ", err)
+	}
+
+	// 列出全部不符合要求的参数
+	type Param struct {
+		VideoParam
+		Name  string   `json:"name" request:"required"`
+		Tags  []string `json:"tags,omitempty" request:"query,omitempty,min=1,max=2"`
+		Level int      `json:"level" request:"min=1"`
+		Type  string   `json:"type" request:"enum=all|archive"`
+	}
+	err = withParams(resty.New().R(), &Param{Tags: []string{"a", "b", "c"}, Type: "live"})
+	if !errors.As(err, &e) {
+		t.Fatal("expected ValidationError, got ", err)
+	}
+	var rules []string
+	for _, f := range e.Fields {
+		rules = append(rules, f.Field+":"+f.Rule)
+	}
+	expected := []string{"name:required", "tags:max", "level:min", "type:enum", "aid|bvid:oneof"}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Fatal("expected ", expected, ", got ", rules)
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
+// VideoParam 是视频相关接口的参数，可以通过 VideoID.Param 从 ParseVideoID 的结果得到
 type VideoParam struct {
-	Aid  int    `json:"aid,omitempty" request:"query,omitempty"`  // 稿件avid。avid与bvid任选一个
-	Bvid string `json:"bvid,omitempty" request:"query,omitempty"` // 稿件bvid。avid与bvid任选一个
+	Aid  int    `json:"aid,omitempty" request:"query,omitempty,oneof=aid|bvid"`  // 稿件avid。avid与bvid任选一个
+	Bvid string `json:"bvid,omitempty" request:"query,omitempty,oneof=aid|bvid"` // 稿件bvid。avid与bvid任选一个
 }
 
 type CardVip struct {
@@ -353,9 +354,9 @@ func (c *Client) HateVideoTag(param VideoTagParam) error {
 }
 
 type LikeVideoParam struct {
-	Aid  int    `json:"aid,omitempty" request:"query,omitempty"`  // 稿件 avid。avid 与 bvid 任选一个
-	Bvid string `json:"bvid,omitempty" request:"query,omitempty"` // 稿件 bvid。avid 与 bvid 任选一个
-	Like int    `json:"like"`                                     // 操作方式。1：点赞。2：取消赞
+	Aid  int    `json:"aid,omitempty" request:"query,omitempty,oneof=aid|bvid"`  // 稿件 avid。avid 与 bvid 任选一个
+	Bvid string `json:"bvid,omitempty" request:"query,omitempty,oneof=aid|bvid"` // 稿件 bvid。avid 与 bvid 任选一个
+	Like int    `json:"like" request:"enum=1|2"`                                 // 操作方式。1：点赞。2：取消赞
 }
 
 // LikeVideo 点赞视频
@@ -369,10 +370,10 @@ func (c *Client) LikeVideo(param LikeVideoParam) error {
 }
 
 type CoinVideoParam struct {
-	Aid        int    `json:"aid,omitempty" request:"query,omitempty"`         // 稿件 avid。avid 与 bvid 任选一个
-	Bvid       string `json:"bvid,omitempty" request:"query,omitempty"`        // 稿件 bvid。avid 与 bvid 任选一个
-	Multiply   int    `json:"multiply"`                                        // 投币数量。上限为2
-	SelectLike int    `json:"select_like,omitempty" request:"query,omitempty"` // 是否附加点赞。0：不点赞。1：同时点赞。默认为0
+	Aid        int    `json:"aid,omitempty" request:"query,omitempty,oneof=aid|bvid"`  // 稿件 avid。avid 与 bvid 任选一个
+	Bvid       string `json:"bvid,omitempty" request:"query,omitempty,oneof=aid|bvid"` // 稿件 bvid。avid 与 bvid 任选一个
+	Multiply   int    `json:"multiply" request:"min=1,max=2"`                          // 投币数量。上限为2
+	SelectLike int    `json:"select_like,omitempty" request:"query,omitempty"`         // 是否附加点赞。0：不点赞。1：同时点赞。默认为0
 }
 
 type CoinVideoResult struct {
@@ -389,7 +390,7 @@ func (c *Client) CoinVideo(param CoinVideoParam) (*CoinVideoResult, error) {
 }
 
 type FavourVideoParam struct {
-	Rid         int   `json:"rid"`                                               // 稿件 avid
+	Rid         int   `json:"rid" request:"required"`                            // 稿件 avid
 	Type        int   `json:"type"`                                              // 必须为2
 	AddMediaIds []int `json:"add_media_ids,omitempty" request:"query,omitempty"` // 需要加入的收藏夹 mlid。同时添加多个，用,（%2C）分隔
 	DelMediaIds []int `json:"del_media_ids,omitempty" request:"query,omitempty"` // 需要取消的收藏夹 mlid。同时取消多个，用,（%2C）分隔
@@ -425,9 +426,9 @@ func (c *Client) LikeCoinFavourVideo(param VideoParam) (*LikeCoinFavourResult, e
 }
 
 type VideoCidParam struct {
-	Aid  int    `json:"aid,omitempty" request:"query,omitempty"`  // 稿件avid。avid与bvid任选一个
-	Bvid string `json:"bvid,omitempty" request:"query,omitempty"` // 稿件bvid。avid与bvid任选一个
-	Cid  int    `json:"cid"`                                      // 视频cid。用于选择目标分P
+	Aid  int    `json:"aid,omitempty" request:"query,omitempty,oneof=aid|bvid"`  // 稿件avid。avid与bvid任选一个
+	Bvid string `json:"bvid,omitempty" request:"query,omitempty,oneof=aid|bvid"` // 稿件bvid。avid与bvid任选一个
+	Cid  int    `json:"cid"`                                                     // 视频cid。用于选择目标分P
 }
 
 type ShowSwitch struct {
@@ -577,18 +578,18 @@ func (c *Client) GetVideoSeriesInfo(param GetVideoSeriesInfoParam) (*VideoCollec
 }
 
 type GetVideoStreamParam struct {
-	Avid        int    `json:"avid,omitempty" request:"query,omitempty"`         // 稿件 avid。avid 与 bvid 任选一个
-	Bvid        string `json:"bvid,omitempty" request:"query,omitempty"`         // 稿件 bvid。avid 与 bvid 任选一个
-	Cid         int    `json:"cid"`                                              // 视频 cid
-	Qn          int    `json:"qn,omitempty" request:"query,omitempty"`           // 视频清晰度选择。未登录默认 32（480P），登录后默认 64（720P）。含义见 [上表](#qn视频清晰度标识)。**DASH 格式时无效**
-	Fnval       int    `json:"fnval,omitempty" request:"query,omitempty"`        // 视频流格式标识。默认值为1（MP4 格式）。含义见 [ 上表](#fnval视频流格式标识)
-	Fnver       int    `json:"fnver,omitempty" request:"query,omitempty"`        // 0
-	Fourk       int    `json:"fourk,omitempty" request:"query,omitempty"`        // 是否允许 4K 视频。画质最高 1080P：0（默认）。画 质最高 4K：1
-	Session     string `json:"session,omitempty" request:"query,omitempty"`      // 从视频播放页的 HTML 中获取
-	Otype       string `json:"otype,omitempty" request:"query,omitempty"`        // 固定为json
-	Type        string `json:"type,omitempty" request:"query,omitempty"`         // 目前为空
-	Platform    string `json:"platform,omitempty" request:"query,omitempty"`     // pc：web播放（默认值，视频流存在 referer鉴权）。html5：移动端 HTML5 播放（仅支持 MP4 格式，无 referer 鉴权可以直接使用video标签播放）
-	HighQuality int    `json:"high_quality,omitempty" request:"query,omitempty"` // 是否高画质。platform=html5时，此值 为1可使画质为1080p
+	Avid        int    `json:"avid,omitempty" request:"query,omitempty,oneof=avid|bvid"` // 稿件 avid。avid 与 bvid 任选一个
+	Bvid        string `json:"bvid,omitempty" request:"query,omitempty,oneof=avid|bvid"` // 稿件 bvid。avid 与 bvid 任选一个
+	Cid         int    `json:"cid" request:"required"`                                   // 视频 cid。可以使用 GetVideoCid 获取
+	Qn          int    `json:"qn,omitempty" request:"query,omitempty"`                   // 视频清晰度选择。未登录默认 32（480P），登录后默认 64（720P）。含义见 [上表](#qn视频清晰度标识)。**DASH 格式时无效**
+	Fnval       int    `json:"fnval,omitempty" request:"query,omitempty"`                // 视频流格式标识。默认值为1（MP4 格式）。含义见 [ 上表](#fnval视频流格式标识)
+	Fnver       int    `json:"fnver,omitempty" request:"query,omitempty"`                // 0
+	Fourk       int    `json:"fourk,omitempty" request:"query,omitempty"`                // 是否允许 4K 视频。画质最高 1080P：0（默认）。画 质最高 4K：1
+	Session     string `json:"session,omitempty" request:"query,omitempty"`              // 从视频播放页的 HTML 中获取
+	Otype       string `json:"otype,omitempty" request:"query,omitempty"`                // 固定为json
+	Type        string `json:"type,omitempty" request:"query,omitempty"`                 // 目前为空
+	Platform    string `json:"platform,omitempty" request:"query,omitempty"`             // pc：web播放（默认值，视频流存在 referer鉴权）。html5：移动端 HTML5 播放（仅支持 MP4 格式，无 referer 鉴权可以直接使用video标签播放）
+	HighQuality int    `json:"high_quality,omitempty" request:"query,omitempty"`         // 是否高画质。platform=html5时，此值 为1可使画质为1080p
 }
 
 type SupportFormat struct {
@@ -677,5 +678,5 @@ func (c *Client) GetVideoStream(param GetVideoStreamParam) (*GetVideoStreamResul
 		method = resty.MethodGet
 		url    = "https://api.bilibili.com/x/player/wbi/playurl"
 	)
-	return execute[*GetVideoStreamResult](c, method, url, param, fillWbiHandler(c.wbi, c.GetCookies()))
+	return executeWbi[*GetVideoStreamResult](c, method, url, param)
 }
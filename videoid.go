@@ -0,0 +1,160 @@
+package bilibili
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VideoID 表示一个视频。视频相关接口的参数都可以通过它的方法得到：Param（VideoParam）、StreamParam、
+// LikeParam、CoinParam、FavourParam、TagParam 和 CidParam，例如：
+//
+//	info, err := client.GetVideoInfo(id.Param())
+//	stream, err := client.GetVideoStreamByID(id, bilibili.GetVideoStreamParam{}.WithFnval(bilibili.FnvalDash))
+//
+// Aid 和 Bvid 填写一个即可，使用 ParseVideoID 解析得到的 VideoID 两个都会填写。Page 是分P的序号，用于 GetVideoCid。
+type VideoID struct {
+	Aid  int    `json:"aid,omitempty"`  // 稿件avid
+	Bvid string `json:"bvid,omitempty"` // 稿件bvid
+	Page int    `json:"-"`              // 分P的序号，从1开始，为0时视为1
+}
+
+// ParseVideoID 解析视频的av号、bv号或者链接，例如：
+//
+//	av170001
+//	BV17x411w7KC
+//	https://www.bilibili.com/video/BV17x411w7KC?p=3
+//
+// av号和bv号的前缀不区分大小写。bv号的字符或者校验不正确时返回错误。
+// b23.tv 的短链接需要发起请求，请使用 Client.ParseVideoID。链接的其它格式见 ParseURL。
+func ParseVideoID(s string) (VideoID, error) {
+	return videoIDOf(ParseURL(s))
+}
+
+// ParseVideoID 与包级别的 ParseVideoID 相同，另外还支持 b23.tv 的短链接（通过 ResolveURL 解析）
+func (c *Client) ParseVideoID(s string) (VideoID, error) {
+	return videoIDOf(c.ResolveURL(s))
+}
+
+func videoIDOf(result ParsedURL, err error) (VideoID, error) {
+	if err != nil {
+		return VideoID{}, err
+	}
+	if result.Kind != URLVideo {
+		return VideoID{}, errors.Wrapf(ErrInvalidParam, "链接不是视频: %s", result.Kind)
+	}
+	return result.Video, nil
+}
+
+// parseVideoNumber 解析av号或者bv号
+func parseVideoNumber(s string) (VideoID, error) {
+	if len(s) > 2 && strings.EqualFold(s[:2], "av") {
+		aid, err := strconv.Atoi(s[2:])
+		if err != nil || aid <= 0 || aid >= bvMaxAid {
+			return VideoID{}, errors.Wrapf(ErrInvalidParam, "av号不正确: %s", s)
+		}
+		return VideoID{Aid: aid, Bvid: Av2Bv(aid)}, nil
+	}
+	if len(s) != 12 || !strings.EqualFold(s[:2], "bv") || s[2] != '1' {
+		return VideoID{}, errors.Wrapf(ErrInvalidParam, "无法解析视频ID: %s", s)
+	}
+	for i := 3; i < len(s); i++ {
+		if strings.IndexByte(bvAlphabet, s[i]) < 0 {
+			return VideoID{}, errors.Wrapf(ErrInvalidParam, "bv号包含不合法的字符: %s", s)
+		}
+	}
+	bvid := "BV" + s[2:]
+	aid := bv2av(bvid)
+	if aid <= 0 || Av2Bv(aid) != bvid {
+		return VideoID{}, errors.Wrapf(ErrInvalidParam, "bv号校验失败: %s", s)
+	}
+	return VideoID{Aid: aid, Bvid: bvid}, nil
+}
+
+// String 返回bv号，分P序号大于1时加上 ?p=
+func (id VideoID) String() string {
+	bvid := id.Bvid
+	if len(bvid) == 0 && id.Aid > 0 {
+		bvid = Av2Bv(id.Aid)
+	}
+	if id.Page > 1 {
+		return bvid + "?p=" + strconv.Itoa(id.Page)
+	}
+	return bvid
+}
+
+// GetVideoCid 通过 GetVideoPageList 获取 id 中指定的分P的 cid，Page 为0时获取第1个分P
+func (c *Client) GetVideoCid(id VideoID) (int, error) {
+	pages, err := c.GetVideoPageList(id.Param())
+	if err != nil {
+		return 0, err
+	}
+	page := id.Page
+	if page == 0 {
+		page = 1
+	}
+	for _, p := range pages {
+		if p.Page == page {
+			return p.Cid, nil
+		}
+	}
+	return 0, errors.Wrapf(ErrInvalidParam, "视频 %s 没有第%d个分P", id, page)
+}
+
+// Param 返回 GetVideoInfo 等接口的参数 VideoParam
+func (id VideoID) Param() VideoParam {
+	return VideoParam{Aid: id.Aid, Bvid: id.Bvid}
+}
+
+// StreamParam 返回 GetVideoStream 的参数，填写了 avid、bvid 和 cid
+func (id VideoID) StreamParam(cid int) GetVideoStreamParam {
+	return GetVideoStreamParam{Avid: id.Aid, Bvid: id.Bvid, Cid: cid}
+}
+
+// GetVideoStreamByID 获取 id 的视频流地址。param 中的 avid 和 bvid 会被替换为 id 中的值，
+// param.Cid 为0时通过 GetVideoCid 获取 id 中指定的分P的 cid
+func (c *Client) GetVideoStreamByID(id VideoID, param GetVideoStreamParam) (*GetVideoStreamResult, error) {
+	param.Avid, param.Bvid = id.Aid, id.Bvid
+	if param.Cid == 0 {
+		cid, err := c.GetVideoCid(id)
+		if err != nil {
+			return nil, err
+		}
+		param.Cid = cid
+	}
+	return c.GetVideoStream(param)
+}
+
+// LikeParam 返回 LikeVideo 的参数。like 为1时点赞，为2时取消赞
+func (id VideoID) LikeParam(like int) LikeVideoParam {
+	return LikeVideoParam{Aid: id.Aid, Bvid: id.Bvid, Like: like}
+}
+
+// CoinParam 返回 CoinVideo 的参数，multiply 是投币数量
+func (id VideoID) CoinParam(multiply int) CoinVideoParam {
+	return CoinVideoParam{Aid: id.Aid, Bvid: id.Bvid, Multiply: multiply}
+}
+
+// FavourParam 返回 FavourVideo 的参数，addMediaIds 和 delMediaIds 分别是需要加入和取消的收藏夹 mlid
+func (id VideoID) FavourParam(addMediaIds, delMediaIds []int) FavourVideoParam {
+	return FavourVideoParam{Rid: id.avid(), Type: 2, AddMediaIds: addMediaIds, DelMediaIds: delMediaIds}
+}
+
+// TagParam 返回 LikeVideoTag 和 HateVideoTag 的参数
+func (id VideoID) TagParam(tagId int) VideoTagParam {
+	return VideoTagParam{Aid: id.avid(), TagId: tagId}
+}
+
+// CidParam 返回 GetVideoOnlineInfo 的参数，cid 可以通过 GetVideoCid 获取
+func (id VideoID) CidParam(cid int) VideoCidParam {
+	return VideoCidParam{Aid: id.Aid, Bvid: id.Bvid, Cid: cid}
+}
+
+// avid 返回稿件avid，用于只接受 avid 的接口。只填写了 Bvid 时通过 Bv2Av 转换
+func (id VideoID) avid() int {
+	if id.Aid == 0 {
+		return Bv2Av(id.Bvid)
+	}
+	return id.Aid
+}
@@ -0,0 +1,106 @@
+package bilibili_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestParseVideoID(t *testing.T) {
+	for s, expected := range map[string]bilibili.VideoID{
+		"av170001":     {Aid: 170001, Bvid: "BV17x411w7KC"},
+		"AV170001":     {Aid: 170001, Bvid: "BV17x411w7KC"},
+		"BV17x411w7KC": {Aid: 170001, Bvid: "BV17x411w7KC"},
+		"bv17x411w7KC": {Aid: 170001, Bvid: "BV17x411w7KC"},
+		"https://www.bilibili.com/video/BV17x411w7KC?p=3":   {Aid: 170001, Bvid: "BV17x411w7KC", Page: 3},
+		"https://m.bilibili.com/video/av170001/":            {Aid: 170001, Bvid: "BV17x411w7KC"},
+		"www.bilibili.com/video/BV1L9Uoa9EUx/?spm_id_from=": {Aid: 111298867365120, Bvid: "BV1L9Uoa9EUx"},
+	} {
+		id, err := bilibili.ParseVideoID(s)
+		if err != nil {
+			t.Fatal(s, err)
+		}
+		if id != expected {
+			t.Fatal(s, " expected ", expected, ", got ", id)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"170001",
+		"av",
+		"av-1",
+		"BV17x411w7K",  // 长度不对
+		"BV17x411w7K0", // 0 不在字母表中
+		"BV27x411w7KC", // 第三位必须是 1
+		"BV17x411wfKC", // 超出av号的范围，校验失败
+		"https://www.bilibili.com/read/cv1",
+		"https://www.bilibili.com/video/BV17x411w7KC?p=0",
+		"https://b23.tv/abcdef",
+	} {
+		if _, err := bilibili.ParseVideoID(s); !errors.Is(err, bilibili.ErrInvalidParam) {
+			t.Fatal(s, " expected bilibili.ErrInvalidParam, got ", err)
+		}
+	}
+
+	if s := (bilibili.VideoID{Aid: 170001, Page: 2}).String(); s != "BV17x411w7KC?p=2" {
+		t.Fatal("unexpected String() ", s)
+	}
+
+	// 只接受 avid 的接口，由 bv号转换
+	id := bilibili.VideoID{Bvid: "BV17x411w7KC"}
+	if param := id.TagParam(4); param.Aid != 170001 || param.TagId != 4 {
+		t.Fatal("TagParam not correct ", param)
+	}
+	if param := id.FavourParam([]int{5}, nil); param.Rid != 170001 || param.Type != 2 || len(param.AddMediaIds) != 1 {
+		t.Fatal("FavourParam not correct ", param)
+	}
+}
+
+func TestGetVideoCid(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	id, err := bilibili.ParseVideoID("https://www.bilibili.com/video/BV17x411w7KC?p=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	videoInfo, err := client.GetVideoInfo(id.Param())
+	if err != nil || videoInfo.Aid != id.Aid {
+		t.Fatal("GetVideoInfo result not correct ", videoInfo, err)
+	}
+	if cid, err := client.GetVideoCid(id); err != nil || cid != 279787 {
+		t.Fatal("expected cid 279787, got ", cid, err)
+	}
+
+	var query url.Values
+	server.Handle("/x/player/wbi/playurl", func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		query = r.URL.Query()
+		return map[string]any{"quality": 80}, nil
+	})
+	if _, err = client.GetVideoStreamByID(id, bilibili.GetVideoStreamParam{Fnval: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("cid") != "279787" || query.Get("bvid") != "BV17x411w7KC" || query.Get("fnval") != "16" {
+		t.Fatal("GetVideoStreamByID query not correct ", query)
+	}
+	server.Handle("/x/player/online/total", func(_ http.ResponseWriter, r *http.Request) (any, error) {
+		query = r.URL.Query()
+		return bilibili.VideoOnlineInfo{Total: "1"}, nil
+	})
+	if _, err = client.GetVideoOnlineInfo(id.CidParam(279787)); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("cid") != "279787" || query.Get("aid") != "170001" {
+		t.Fatal("GetVideoOnlineInfo query not correct ", query)
+	}
+	id.Page = 3
+	if _, err = client.GetVideoCid(id); !errors.Is(err, bilibili.ErrInvalidParam) {
+		t.Fatal("expected ErrInvalidParam, got ", err)
+	}
+}
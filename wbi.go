@@ -1,8 +1,10 @@
 package bilibili
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"sort"
@@ -16,24 +18,25 @@ import (
 	"golang.org/x/sync/singleflight"
 )
 
-const (
-	cacheImgKey = "imgKey"
-	cacheSubKey = "subKey"
-)
+// cacheKeys 是 wbi keys 在 Storage 中的 key
+const cacheKeys = "wbiKeys"
 
-var (
-	_defaultMixinKeyEncTab = []int{
-		46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
-		33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
-		61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
-		36, 20, 34, 44, 52,
-	}
+var _defaultMixinKeyEncTab = []int{
+	46, 47, 18, 2, 53, 8, 23, 32, 15, 50, 10, 31, 58, 3, 45, 35, 27, 43, 5, 49,
+	33, 9, 42, 19, 29, 28, 14, 39, 12, 38, 41, 13, 37, 48, 7, 16, 24, 55, 40,
+	61, 26, 17, 0, 1, 60, 51, 30, 4, 22, 25, 54, 21, 56, 59, 6, 63, 57, 62, 11,
+	36, 20, 34, 44, 52,
+}
 
-	_defaultStorage = &MemoryStorage{
-		data: make(map[string]interface{}, 15),
-	}
-)
+// maxInitAttempts 是一次 GetKeys 中最多尝试获取 wbi keys 的次数
+const maxInitAttempts = 2
+
+// ErrWbiKeysUnavailable 表示无法获取 wbi keys
+var ErrWbiKeysUnavailable = errors.New("获取 wbi keys 失败")
 
+// Storage 储存 wbi keys。WBI 保存的值总是 string，方便实现基于文件、Redis 等的 Storage，在多个进程之间共享 wbi keys。
+//
+// 实现需要是并发安全的。Get 返回 []byte 也可以。
 type Storage interface {
 	Set(key string, value interface{})
 	Get(key string) (v interface{}, isSet bool)
@@ -44,10 +47,18 @@ type MemoryStorage struct {
 	mu   sync.RWMutex
 }
 
+// NewMemoryStorage 返回一个空的 MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: make(map[string]interface{}, 2)}
+}
+
 func (impl *MemoryStorage) Set(key string, value interface{}) {
 	impl.mu.Lock()
 	defer impl.mu.Unlock()
 
+	if impl.data == nil {
+		impl.data = make(map[string]interface{}, 2)
+	}
 	impl.data[key] = value
 }
 
@@ -61,10 +72,42 @@ func (impl *MemoryStorage) Get(key string) (v interface{}, isSet bool) {
 	return nil, false
 }
 
+// WbiKeys 是一组 wbi keys 和它的过期时间
+type WbiKeys struct {
+	ImgKey    string
+	SubKey    string
+	ExpiresAt time.Time
+}
+
+// String 把 WbiKeys 编码为 "imgKey:subKey:过期时间戳" 的格式，这就是保存在 Storage 中的值
+func (k WbiKeys) String() string {
+	return k.ImgKey + ":" + k.SubKey + ":" + strconv.FormatInt(k.ExpiresAt.Unix(), 10)
+}
+
+// ParseWbiKeys 解析 WbiKeys.String 编码的字符串
+func ParseWbiKeys(s string) (WbiKeys, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return WbiKeys{}, errors.Errorf("wbi keys 格式错误: %q", s)
+	}
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return WbiKeys{}, errors.WithStack(err)
+	}
+	return WbiKeys{ImgKey: parts[0], SubKey: parts[1], ExpiresAt: time.Unix(expiresAt, 0)}, nil
+}
+
+func (k WbiKeys) expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
+
 // WBI 签名实现
 // 如果希望以登录的方式获取则使用 WithCookies or WithRawCookies 设置cookie
 // 如果希望以未登录的方式获取 WithCookies(nil) 设置cookie为 nil 即可, 这是 Default 行为
 //
+// 通过 Client.Wbi 获取的 WBI 默认使用 Client 的 cookies 和 *resty.Client 设置（代理、超时等）获取 wbi keys。
+// 每个 WBI 默认使用自己的 MemoryStorage，可以通过 WithStorage 在多个 Client 甚至多个进程之间共享 wbi keys。
+//
 //	!!! 使用 WBI 的接口 绝对不可以 set header Referer 会导致失败 !!!
 //	!!! 大部分使用 WBI 的接口都需要 set header Cookie !!!
 //
@@ -74,11 +117,13 @@ type WBI struct {
 	mixinKeyEncTab []int
 
 	// updateCheckerInterval is the interval to check and update wbi keys
-	// default is 60 minutes. so if lastInitTime + updateCheckerInterval < now, it will update wbi keys
+	// default is 60 minutes. wbi keys expire after updateCheckerInterval, and the expiry is saved in storage with the keys
 	updateCheckerInterval time.Duration
-	lastInitTime          time.Time
 	storage               Storage
 
+	// client 是所属的 Client，用于发起获取 wbi keys 的请求，为 nil 时使用一个默认的 *resty.Client
+	client *Client
+
 	sfg singleflight.Group
 }
 
@@ -88,7 +133,7 @@ func NewDefaultWbi() *WBI {
 		mixinKeyEncTab: _defaultMixinKeyEncTab,
 
 		updateCheckerInterval: 60 * time.Minute,
-		storage:               _defaultStorage,
+		storage:               NewMemoryStorage(),
 	}
 }
 
@@ -97,6 +142,7 @@ func (wbi *WBI) WithUpdateInterval(updateInterval time.Duration) *WBI {
 	return wbi
 }
 
+// WithCookies 设置获取 wbi keys 时使用的cookies。属于 Client 的 WBI 默认使用 Client 的cookies
 func (wbi *WBI) WithCookies(cookies []*http.Cookie) *WBI {
 	wbi.cookies = cookies
 	return wbi
@@ -122,40 +168,78 @@ func (wbi *WBI) WithStorage(storage Storage) *WBI {
 }
 
 func (wbi *WBI) GetKeys() (imgKey string, subKey string, err error) {
-	imgKey, subKey = wbi.getKeys()
-
-	// 更新检查
-	if imgKey == "" || subKey == "" || time.Since(wbi.lastInitTime) > wbi.updateCheckerInterval {
-		if err = wbi.initWbi(); err != nil {
-			return "", "", err
-		}
+	return wbi.GetKeysContext(context.Background())
+}
 
-		return wbi.GetKeys()
+// GetKeysContext 同 GetKeys，ctx 用于控制刷新 wbi keys 时发起的请求。
+//
+// wbi keys 过期时会重新获取，最多尝试 maxInitAttempts 次；如果都失败了但还有过期的 wbi keys，则继续使用过期的 wbi keys。
+func (wbi *WBI) GetKeysContext(ctx context.Context) (imgKey string, subKey string, err error) {
+	keys, ok := wbi.loadKeys()
+	if ok && !keys.expired(time.Now()) {
+		return keys.ImgKey, keys.SubKey, nil
 	}
 
-	return imgKey, subKey, nil
+	for attempt := 0; attempt < maxInitAttempts; attempt++ {
+		if err = wbi.initWbi(ctx); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if keys, ok = wbi.loadKeys(); ok {
+			return keys.ImgKey, keys.SubKey, nil
+		}
+	}
+	if ok {
+		return keys.ImgKey, keys.SubKey, nil
+	}
+	if err == nil {
+		err = errors.WithStack(ErrWbiKeysUnavailable)
+	}
+	return "", "", err
 }
 
-func (wbi *WBI) getKeys() (imgKey string, subKey string) {
-	if v, isSet := wbi.storage.Get(cacheImgKey); isSet {
-		imgKey = v.(string)
+// loadKeys 从 storage 中读取 wbi keys，不检查是否过期
+func (wbi *WBI) loadKeys() (WbiKeys, bool) {
+	v, isSet := wbi.storage.Get(cacheKeys)
+	if !isSet {
+		return WbiKeys{}, false
 	}
-
-	if v, isSet := wbi.storage.Get(cacheSubKey); isSet {
-		subKey = v.(string)
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return WbiKeys{}, false
 	}
-
-	return imgKey, subKey
+	keys, err := ParseWbiKeys(s)
+	return keys, err == nil
 }
 
+// SetKeys 设置 wbi keys，它们会在 updateCheckerInterval 后过期
 func (wbi *WBI) SetKeys(imgKey, subKey string) {
-	wbi.storage.Set(cacheImgKey, imgKey)
-	wbi.storage.Set(cacheSubKey, subKey)
-	wbi.lastInitTime = time.Now()
+	wbi.storage.Set(cacheKeys, WbiKeys{
+		ImgKey:    imgKey,
+		SubKey:    subKey,
+		ExpiresAt: time.Now().Add(wbi.updateCheckerInterval),
+	}.String())
+}
+
+// Refresh 立即重新获取 wbi keys，不管它们是否过期。并发调用只会发起一次请求
+func (wbi *WBI) Refresh(ctx context.Context) error {
+	return wbi.initWbi(ctx)
 }
 
 func (wbi *WBI) GetMixinKey() (string, error) {
-	imgKey, subKey, err := wbi.GetKeys()
+	return wbi.GetMixinKeyContext(context.Background())
+}
+
+// GetMixinKeyContext 同 GetMixinKey，ctx 用于控制刷新 wbi keys 时发起的请求
+func (wbi *WBI) GetMixinKeyContext(ctx context.Context) (string, error) {
+	imgKey, subKey, err := wbi.GetKeysContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -182,13 +266,18 @@ func (wbi *WBI) sanitizeString(s string) string {
 }
 
 func (wbi *WBI) SignQuery(query url.Values, ts time.Time) (newQuery url.Values, err error) {
+	return wbi.SignQueryContext(context.Background(), query, ts)
+}
+
+// SignQueryContext 同 SignQuery，ctx 用于控制刷新 wbi keys 时发起的请求
+func (wbi *WBI) SignQueryContext(ctx context.Context, query url.Values, ts time.Time) (newQuery url.Values, err error) {
 	payload := make(map[string]string, 10)
 	for k := range query {
 		payload[k] = query.Get(k)
 
 	}
 
-	newPayload, err := wbi.SignMap(payload, ts)
+	newPayload, err := wbi.SignMapContext(ctx, payload, ts)
 	if err != nil {
 		return query, err
 	}
@@ -202,6 +291,11 @@ func (wbi *WBI) SignQuery(query url.Values, ts time.Time) (newQuery url.Values,
 }
 
 func (wbi *WBI) SignMap(payload map[string]string, ts time.Time) (newPayload map[string]string, err error) {
+	return wbi.SignMapContext(context.Background(), payload, ts)
+}
+
+// SignMapContext 同 SignMap，ctx 用于控制刷新 wbi keys 时发起的请求
+func (wbi *WBI) SignMapContext(ctx context.Context, payload map[string]string, ts time.Time) (newPayload map[string]string, err error) {
 	newPayload = make(map[string]string, 10)
 	for k, v := range payload {
 		newPayload[k] = v
@@ -231,7 +325,7 @@ func (wbi *WBI) SignMap(payload map[string]string, ts time.Time) (newPayload map
 	signQueryStr := signQuery.Encode()
 
 	// Get mixin key
-	mixinKey, err := wbi.GetMixinKey()
+	mixinKey, err := wbi.GetMixinKeyContext(ctx)
 	if err != nil {
 		return payload, err
 	}
@@ -243,20 +337,67 @@ func (wbi *WBI) SignMap(payload map[string]string, ts time.Time) (newPayload map
 	return newPayload, nil
 }
 
-func (wbi *WBI) initWbi() error {
-	_, err, _ := wbi.sfg.Do("initWbi", func() (interface{}, error) {
-		return nil, wbi.doInitWbi()
+// wbiFetchTimeout 是刷新 wbi keys 的请求的超时时间
+const wbiFetchTimeout = 30 * time.Second
+
+// initWbi 刷新 wbi keys，并发调用只会发起一次请求。
+// 请求不随 ctx 取消，以免影响同时等待的其它调用者；ctx 取消时立即返回，不再等待刷新结果。
+func (wbi *WBI) initWbi(ctx context.Context) error {
+	ch := wbi.sfg.DoChan("initWbi", func() (interface{}, error) {
+		ctx, cancel := detach(ctx, wbiFetchTimeout)
+		defer cancel()
+		return nil, wbi.doInitWbi(ctx)
 	})
 
-	if err != nil {
-		return errors.WithStack(err)
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			return errors.WithStack(res.Err)
+		}
+		return nil
 	}
+}
 
-	return nil
+// wbiNavUrl 是获取 wbi keys 的接口地址
+const wbiNavUrl = "https://api.bilibili.com/x/web-interface/nav"
+
+// newNavRequest 创建获取 wbi keys 的请求，属于 Client 时使用 Client 的设置和cookies
+func (wbi *WBI) newNavRequest(ctx context.Context) (r *resty.Request, requestUrl string) {
+	if wbi.client != nil {
+		r = wbi.client.WithContext(ctx).newRequest(wbiNavUrl)
+		requestUrl = wbi.client.resolveUrl(wbiNavUrl)
+	} else {
+		r = resty.New().R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/json").
+			SetHeader("Accept-Language", "zh-CN,zh;q=0.9").
+			SetHeader("Origin", "https://www.bilibili.com").
+			SetHeader("Referer", "https://www.bilibili.com/").
+			SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0")
+		requestUrl = wbiNavUrl
+	}
+	if wbi.cookies != nil {
+		r.Cookies = wbi.cookies
+	}
+	return r, requestUrl
 }
 
-func (wbi *WBI) doInitWbi() error {
-	result := struct {
+func (wbi *WBI) doInitWbi(ctx context.Context) error {
+	if wbi.client == nil || wbi.client.proxyPool == nil {
+		return wbi.fetchKeys(ctx)
+	}
+	// 记录请求使用的代理，被风控时让该代理进入冷却
+	call := &Call{Context: ctx, Endpoint: "/x/web-interface/nav", Method: http.MethodGet, Url: wbiNavUrl}
+	err := wbi.fetchKeys(context.WithValue(ctx, callKey{}, call))
+	wbi.client.reportProxy(call, err)
+	return err
+}
+
+// fetchKeys 请求 nav 接口获取并保存 wbi keys
+func (wbi *WBI) fetchKeys(ctx context.Context) error {
+	var result struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 		Data    struct {
@@ -265,39 +406,48 @@ func (wbi *WBI) doInitWbi() error {
 				SubUrl string `json:"sub_url"`
 			} `json:"wbi_img"`
 		}
-	}{}
-
-	resp, err := resty.New().R().
-		SetHeader("Accept", "application/json").
-		SetHeader("Accept-Language", "zh-CN,zh;q=0.9").
-		SetHeader("Origin", "https://www.bilibili.com").
-		SetHeader("Referer", "https://www.bilibili.com/").
-		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0").
-		SetCookies(wbi.cookies).
-		SetResult(&result).
-		Get("https://api.bilibili.com/x/web-interface/nav")
+	}
 
+	r, navUrl := wbi.newNavRequest(ctx)
+	resp, err := r.Get(navUrl)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.Errorf("status code: %d", resp.StatusCode())
+		return errors.WithStack(StatusError{StatusCode: resp.StatusCode()})
+	}
+	// 不依赖响应的 Content-Type，自行解析
+	if err = json.Unmarshal(resp.Body(), &result); err != nil {
+		return errors.WithStack(err)
 	}
 
-	if result.Code != 0 {
-		if result.Data.WbiImg.ImgUrl == "" || result.Data.WbiImg.SubUrl == "" {
-			return errors.Errorf("init wbi 失败, 错误码: %d, 错误信息: %s", result.Code, result.Message)
+	imgKey := wbiKeyFromUrl(result.Data.WbiImg.ImgUrl)
+	subKey := wbiKeyFromUrl(result.Data.WbiImg.SubUrl)
+	if imgKey == "" || subKey == "" {
+		if result.Code != 0 {
+			return errors.Wrap(Error{Code: result.Code, Message: result.Message}, "init wbi 失败")
 		}
+		return errors.WithStack(ErrWbiKeysUnavailable)
 	}
 
 	if len(resp.Cookies()) > 0 {
 		// update cookie
-		wbi.cookies = resp.Cookies()
+		if wbi.client != nil && wbi.cookies == nil {
+			wbi.client.updateCookies(wbiNavUrl, resp.Cookies())
+		} else {
+			wbi.cookies = resp.Cookies()
+		}
 	}
 
-	imgKey := strings.Split(strings.Split(result.Data.WbiImg.ImgUrl, "/")[len(strings.Split(result.Data.WbiImg.ImgUrl, "/"))-1], ".")[0]
-	subKey := strings.Split(strings.Split(result.Data.WbiImg.SubUrl, "/")[len(strings.Split(result.Data.WbiImg.SubUrl, "/"))-1], ".")[0]
-
 	wbi.SetKeys(imgKey, subKey)
 	return nil
 }
+
+// wbiKeyFromUrl 从形如 https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png 的地址中取出 key
+func wbiKeyFromUrl(u string) string {
+	name := u[strings.LastIndex(u, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
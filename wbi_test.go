@@ -0,0 +1,123 @@
+package bilibili_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rinkurt/bilibili"
+	"github.com/rinkurt/bilibili/bilibilitest"
+)
+
+func TestWbiKeys(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const navPath = "/x/web-interface/nav"
+	server.SetWbiKeys("", "")
+
+	c := server.NewClient()
+	if _, _, err := c.Wbi().GetKeys(); !bilibili.IsNotLoggedIn(err) || server.RequestCount(navPath) != bilibili.MaxWbiInitAttempts {
+		t.Fatal("empty wbi keys should fail after bounded attempts, got ", err, server.RequestCount(navPath))
+	}
+
+	server.SetWbiKeys("img1", "sub1")
+	imgKey, subKey, err := c.Wbi().GetKeys()
+	if err != nil || imgKey != "img1" || subKey != "sub1" {
+		t.Fatal("GetKeys result not correct ", imgKey, subKey, err)
+	}
+	if other, _, _ := bilibili.New().SetAllHosts("http://127.0.0.1:1").Wbi().WithUpdateInterval(time.Hour).GetKeys(); other == "img1" {
+		t.Fatal("wbi keys should not be shared between clients by default")
+	}
+
+	// 共享 Storage 时，过期时间也保存在 Storage 中
+	storage := bilibili.NewMemoryStorage()
+	storage.Set(bilibili.WbiCacheKey, bilibili.WbiKeys{ImgKey: "img2", SubKey: "sub2", ExpiresAt: time.Now().Add(time.Hour)}.String())
+	navCount := server.RequestCount(navPath)
+	c.Wbi().WithStorage(storage)
+	if imgKey, _, _ = c.Wbi().GetKeys(); imgKey != "img2" || server.RequestCount(navPath) != navCount {
+		t.Fatal("wbi keys in storage should be used before expired ", imgKey)
+	}
+	storage.Set(bilibili.WbiCacheKey, bilibili.WbiKeys{ImgKey: "img2", SubKey: "sub2", ExpiresAt: time.Now().Add(-time.Second)}.String())
+	if imgKey, _, _ = c.Wbi().GetKeys(); imgKey != "img1" || server.RequestCount(navPath) != navCount+1 {
+		t.Fatal("expired wbi keys should be refreshed ", imgKey)
+	}
+	saved, _ := storage.Get(bilibili.WbiCacheKey)
+	keys, err := bilibili.ParseWbiKeys(saved.(string))
+	if err != nil || keys.ImgKey != "img1" || !keys.ExpiresAt.After(time.Now()) {
+		t.Fatal("refreshed wbi keys should be saved with expiry ", keys, err)
+	}
+
+	// 刷新失败时继续使用过期的 wbi keys
+	storage.Set(bilibili.WbiCacheKey, bilibili.WbiKeys{ImgKey: "img3", SubKey: "sub3", ExpiresAt: time.Now().Add(-time.Second)}.String())
+	server.Handle(navPath, func(http.ResponseWriter, *http.Request) (any, error) {
+		return nil, bilibili.StatusError{StatusCode: http.StatusBadGateway}
+	})
+	if imgKey, _, err = c.Wbi().GetKeys(); err != nil || imgKey != "img3" {
+		t.Fatal("stale wbi keys should be used when refresh fails ", imgKey, err)
+	}
+}
+
+func TestWbiRefreshCancel(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	const navPath = "/x/web-interface/nav"
+	release := make(chan struct{})
+	server.Handle(navPath, func(http.ResponseWriter, *http.Request) (any, error) {
+		<-release
+		return map[string]any{"wbi_img": map[string]any{
+			"img_url": "https://i0.hdslb.com/bfs/wbi/img1.png",
+			"sub_url": "https://i0.hdslb.com/bfs/wbi/sub1.png",
+		}}, nil
+	})
+	c := server.NewClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := c.WithContext(ctx).Wbi().GetKeysContext(ctx)
+		leaderErr <- err
+	}()
+	for server.RequestCount(navPath) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	followerErr := make(chan error, 1)
+	var imgKey string
+	go func() {
+		var err error
+		imgKey, _, err = c.Wbi().GetKeys()
+		followerErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 发起刷新的调用被取消，不影响等待同一次刷新的调用
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got ", err)
+	}
+	close(release)
+	if err := <-followerErr; err != nil || imgKey != "img1" {
+		t.Fatal("follower should get the refreshed wbi keys ", imgKey, err)
+	}
+	if count := server.RequestCount(navPath); count != 1 {
+		t.Fatal("nav request count ", count)
+	}
+}
+
+func TestWbiKeyRotation(t *testing.T) {
+	server := bilibilitest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	if _, err := client.GetUserVideos(bilibili.GetUserVideosParam{Mid: 2}); err != nil {
+		t.Fatal(err)
+	}
+	server.SetWbiKeys("653657f524a547ac981ded72ea172057", "6e4909c702f846728e64f6007736a338")
+	if _, err := client.GetUserVideos(bilibili.GetUserVideosParam{Mid: 2}); err != nil {
+		t.Fatal("request should be re-signed after wbi keys rotated ", err)
+	}
+	if count := server.RequestCount("/x/web-interface/nav"); count != 2 {
+		t.Fatal("expected 2 nav requests, got ", count)
+	}
+}